@@ -0,0 +1,165 @@
+// Package backup implements backing up and restoring SpiceDB in the file
+// format used by `zed backup`.
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jzelinskie/gochugaru/client"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// Create writes a backup of the schema and all relationships in SpiceDB to
+// the provided writer and returns the revision at which it was taken.
+//
+// The relationships are exported at the same revision as the schema, so the
+// backup is a consistent snapshot even while SpiceDB is being written to.
+func Create(ctx context.Context, c *client.Client, w io.Writer) (revision string, err error) {
+	schema, revision, err := c.ReadSchema(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	enc, err := NewEncoder(w, schema, revision)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := c.ExportRelationships(ctx, enc.Append, revision); err != nil {
+		return "", fmt.Errorf("failed to export relationships: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("failed to finish backup: %w", err)
+	}
+	return revision, nil
+}
+
+// ErrEmptyRewritePrefix is returned by Restore when a prefix rewrite has an
+// empty prefix to replace, which would match every identifier.
+var ErrEmptyRewritePrefix = errors.New("prefix rewrite requires a non-empty prefix to replace")
+
+// RestoreOption configures a call to Restore.
+type RestoreOption func(*restoreOptions)
+
+type restoreOptions struct {
+	prefixFilter   string
+	prefixRewrites [][2]string
+	importOpts     []client.ImportOption
+	err            error
+}
+
+// WithPrefixFilter configures Restore to only restore the definitions,
+// caveats, and relationships whose object types have the provided prefix
+// (e.g. "tenant1").
+//
+// Relationships are only restored if their resource, subject, and caveat (if
+// any) have the prefix. Filtering occurs before any prefixes are rewritten.
+func WithPrefixFilter(prefix string) RestoreOption {
+	return func(o *restoreOptions) { o.prefixFilter = normalizePrefix(prefix) }
+}
+
+// WithPrefixRewrite configures Restore to replace the prefix of object types
+// and caveat names (e.g. "tenant1" to "tenant2") in both the schema and the
+// relationships.
+//
+// Rewriting to an empty prefix removes the prefix, but the prefix being
+// replaced cannot be empty. This option can be provided multiple times to
+// rewrite multiple prefixes.
+func WithPrefixRewrite(from, to string) RestoreOption {
+	return func(o *restoreOptions) {
+		if normalizePrefix(from) == "" {
+			o.err = ErrEmptyRewritePrefix
+			return
+		}
+		o.prefixRewrites = append(o.prefixRewrites, [2]string{normalizePrefix(from), normalizePrefix(to)})
+	}
+}
+
+// WithImportOptions configures the call to Client.ImportRelationships used
+// to restore relationships.
+func WithImportOptions(opts ...client.ImportOption) RestoreOption {
+	return func(o *restoreOptions) { o.importOpts = append(o.importOpts, opts...) }
+}
+
+// Restore writes the schema and relationships from a backup to SpiceDB and
+// returns the number of relationships restored.
+//
+// Restoring is intended for an empty SpiceDB: the schema in the backup
+// replaces any existing schema and relationships are restored with
+// Client.ImportRelationships.
+func Restore(ctx context.Context, c *client.Client, r io.Reader, opts ...RestoreOption) (restored uint64, err error) {
+	o := &restoreOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.err != nil {
+		return 0, o.err
+	}
+
+	dec, err := NewDecoder(r)
+	if err != nil {
+		return 0, err
+	}
+
+	schema := dec.Schema()
+	if o.prefixFilter != "" {
+		schema = filterSchemaPrefix(schema, o.prefixFilter)
+	}
+	for _, rewrite := range o.prefixRewrites {
+		schema = rewriteSchemaPrefix(schema, rewrite[0], rewrite[1])
+	}
+
+	if _, err := c.WriteSchema(ctx, schema); err != nil {
+		return 0, fmt.Errorf("failed to write schema: %w", err)
+	}
+
+	restored, err = c.ImportRelationships(ctx, o.source(dec), o.importOpts...)
+	if err != nil {
+		return restored, fmt.Errorf("failed to import relationships: %w", err)
+	}
+	return restored, nil
+}
+
+// source returns a RelationshipSource of the filtered and rewritten
+// relationships in the backup.
+func (o *restoreOptions) source(dec *Decoder) client.RelationshipSource {
+	return func(ctx context.Context) (rel.Relationship, error) {
+		for {
+			if err := ctx.Err(); err != nil {
+				return rel.Relationship{}, err
+			}
+
+			r, err := dec.Next()
+			if errors.Is(err, io.EOF) {
+				return rel.Relationship{}, io.EOF
+			} else if err != nil {
+				return rel.Relationship{}, err
+			}
+
+			if !strings.HasPrefix(r.ResourceType, o.prefixFilter) || !strings.HasPrefix(r.SubjectType, o.prefixFilter) {
+				continue
+			} else if r.CaveatName != "" && !strings.HasPrefix(r.CaveatName, o.prefixFilter) {
+				continue
+			}
+
+			for _, rewrite := range o.prefixRewrites {
+				r.ResourceType = rewritePrefix(r.ResourceType, rewrite[0], rewrite[1])
+				r.SubjectType = rewritePrefix(r.SubjectType, rewrite[0], rewrite[1])
+				r.CaveatName = rewritePrefix(r.CaveatName, rewrite[0], rewrite[1])
+			}
+			return r, nil
+		}
+	}
+}
+
+func rewritePrefix(name, from, to string) string {
+	if trimmed, ok := strings.CutPrefix(name, from); ok {
+		return to + trimmed
+	}
+	return name
+}
@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+
+	"github.com/jzelinskie/gochugaru/client"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+func TestRestoreRejectsEmptyRewritePrefix(t *testing.T) {
+	// The options are validated before the client or backup are used.
+	_, err := Restore(context.Background(), nil, nil, WithPrefixRewrite("", "tenant2"))
+	if !errors.Is(err, ErrEmptyRewritePrefix) {
+		t.Fatalf("expected ErrEmptyRewritePrefix, got %v", err)
+	}
+}
+
+// spiceDBServer stores a schema and relationships written to it and serves
+// them back for backups.
+type spiceDBServer struct {
+	v1.UnimplementedSchemaServiceServer
+	v1.UnimplementedPermissionsServiceServer
+
+	mu     sync.Mutex
+	schema string
+	rels   []*v1.Relationship
+}
+
+func (s *spiceDBServer) ReadSchema(context.Context, *v1.ReadSchemaRequest) (*v1.ReadSchemaResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &v1.ReadSchemaResponse{SchemaText: s.schema, ReadAt: &v1.ZedToken{Token: "revision"}}, nil
+}
+
+func (s *spiceDBServer) WriteSchema(_ context.Context, req *v1.WriteSchemaRequest) (*v1.WriteSchemaResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schema = req.Schema
+	return &v1.WriteSchemaResponse{WrittenAt: &v1.ZedToken{Token: "written"}}, nil
+}
+
+func (s *spiceDBServer) ExportBulkRelationships(_ *v1.ExportBulkRelationshipsRequest, stream v1.PermissionsService_ExportBulkRelationshipsServer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return stream.Send(&v1.ExportBulkRelationshipsResponse{
+		AfterResultCursor: &v1.Cursor{Token: "end"},
+		Relationships:     s.rels,
+	})
+}
+
+func (s *spiceDBServer) ImportBulkRelationships(stream v1.PermissionsService_ImportBulkRelationshipsServer) error {
+	var loaded uint64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&v1.ImportBulkRelationshipsResponse{NumLoaded: loaded})
+		} else if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		s.rels = append(s.rels, req.Relationships...)
+		s.mu.Unlock()
+		loaded += uint64(len(req.Relationships))
+	}
+}
+
+func newTestClient(t *testing.T, srv *spiceDBServer) *client.Client {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer()
+	v1.RegisterSchemaServiceServer(s, srv)
+	v1.RegisterPermissionsServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	c, err := client.NewPlaintext(lis.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCreateRestoreRoundTrip(t *testing.T) {
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 6000, time.UTC)
+	src := &spiceDBServer{schema: tenantSchema}
+	for _, r := range []rel.Relationship{
+		rel.MustFromTriple("tenant1/document:example", "viewer", "tenant1/user:jzelinskie").WithExpiration(expiration),
+		rel.MustFromTriple("tenant1/document:example", "viewer", "tenant1/user:ecordell").WithCaveat("tenant1/is_weekday", map[string]any{"day": "monday"}),
+		rel.MustFromTriple("tenant1/document:example", "viewer", "tenant1/user:vroldanbet").WithCaveat("is_weekend", nil),
+		rel.MustFromTriple("tenant1/document:example", "viewer", "tenant2/user:josephschorr"),
+		rel.MustFromTriple("tenant2/document:example", "viewer", "tenant2/user:jzelinskie"),
+	} {
+		src.rels = append(src.rels, r.V1Proto())
+	}
+
+	var buf bytes.Buffer
+	revision, err := Create(context.Background(), newTestClient(t, src), &buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if revision != "revision" {
+		t.Fatalf("unexpected revision: %q", revision)
+	}
+
+	dst := &spiceDBServer{}
+	restored, err := Restore(context.Background(), newTestClient(t, dst), &buf,
+		WithPrefixFilter("tenant1"),
+		WithPrefixRewrite("tenant1", "tenant3"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	} else if restored != 2 {
+		t.Fatalf("expected 2 relationships to be restored, got %d", restored)
+	}
+
+	expectedSchema := rewriteSchemaPrefix(filterSchemaPrefix(tenantSchema, "tenant1/"), "tenant1/", "tenant3/")
+	if dst.schema != expectedSchema {
+		t.Fatalf("unexpected schema:\n%s", dst.schema)
+	}
+
+	var got []rel.Relationship
+	for _, r := range dst.rels {
+		got = append(got, *rel.FromV1Proto(r))
+	}
+	expected := []rel.Relationship{
+		rel.MustFromTriple("tenant3/document:example", "viewer", "tenant3/user:jzelinskie").WithExpiration(expiration),
+		rel.MustFromTriple("tenant3/document:example", "viewer", "tenant3/user:ecordell").WithCaveat("tenant3/is_weekday", map[string]any{"day": "monday"}),
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected relationships:\n%v\nexpected:\n%v", got, expected)
+	}
+}
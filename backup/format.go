@@ -0,0 +1,263 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// These names are shared with the zed CLI and must not change.
+const (
+	avroNamespace     = "com.authzed.spicedb.backup"
+	metadataKeyZT     = "com.authzed.spicedb.zedtoken.v1"
+	relationshipV1Key = avroNamespace + ".RelationshipV1"
+	schemaV1Key       = avroNamespace + ".SchemaV1"
+)
+
+// ErrMissingSchema is returned when decoding a backup that does not begin
+// with a schema.
+var ErrMissingSchema = errors.New("backup does not contain a schema")
+
+// relationshipV1 is the record used to store each relationship.
+type relationshipV1 struct {
+	ObjectType        string     `avro:"object_type"`
+	ObjectID          string     `avro:"object_id"`
+	Relation          string     `avro:"relation"`
+	SubjectObjectType string     `avro:"subject_object_type"`
+	SubjectObjectID   string     `avro:"subject_object_id"`
+	SubjectRelation   string     `avro:"subject_relation"`
+	CaveatName        string     `avro:"caveat_name"`
+	CaveatContext     []byte     `avro:"caveat_context"`
+	Expiration        *time.Time `avro:"expiration"`
+}
+
+// schemaV1 is the record used to store the schema at the start of a backup.
+type schemaV1 struct {
+	SchemaText string `avro:"schema_text"`
+}
+
+// avroSchemaV1 is a union of the records stored in a backup.
+//
+// The expiration of relationships is optional so that backups without it
+// can still be decoded.
+const avroSchemaV1 = `[
+	{
+		"type": "record",
+		"name": "RelationshipV1",
+		"namespace": "` + avroNamespace + `",
+		"fields": [
+			{"name": "object_type", "type": "string"},
+			{"name": "object_id", "type": "string"},
+			{"name": "relation", "type": "string"},
+			{"name": "subject_object_type", "type": "string"},
+			{"name": "subject_object_id", "type": "string"},
+			{"name": "subject_relation", "type": "string"},
+			{"name": "caveat_name", "type": "string"},
+			{"name": "caveat_context", "type": "bytes"},
+			{"name": "expiration", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}], "default": null}
+		]
+	},
+	{
+		"type": "record",
+		"name": "SchemaV1",
+		"namespace": "` + avroNamespace + `",
+		"fields": [
+			{"name": "schema_text", "type": "string"}
+		]
+	}
+]`
+
+// avroAPI resolves the union's records to their Go types without modifying
+// the global avro configuration.
+var avroAPI = func() avro.API {
+	api := avro.Config{}.Freeze()
+	api.Register(relationshipV1Key, relationshipV1{})
+	api.Register(schemaV1Key, schemaV1{})
+	return api
+}()
+
+// Encoder writes a backup in the format used by `zed backup`.
+type Encoder struct {
+	enc *ocf.Encoder
+}
+
+// NewEncoder writes the header of a backup of the provided schema at the
+// provided revision.
+//
+// Relationships exported at the same revision must then be appended before
+// closing the Encoder.
+func NewEncoder(w io.Writer, schema, revision string) (*Encoder, error) {
+	enc, err := ocf.NewEncoder(avroSchemaV1, w,
+		ocf.WithCodec(ocf.Snappy),
+		ocf.WithMetadata(map[string][]byte{metadataKeyZT: []byte(revision)}),
+		ocf.WithEncodingConfig(avroAPI),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup encoder: %w", err)
+	}
+
+	if err := enc.Encode(schemaV1{SchemaText: schema}); err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	return &Encoder{enc: enc}, nil
+}
+
+// Append adds a relationship to the backup.
+//
+// Append can be used directly as the rel.Func of Client.ExportRelationships.
+// Expirations are stored with microsecond precision, which matches SpiceDB.
+func (e *Encoder) Append(r *rel.Relationship) error {
+	record := relationshipV1{
+		ObjectType:        r.ResourceType,
+		ObjectID:          r.ResourceID,
+		Relation:          r.ResourceRelation,
+		SubjectObjectType: r.SubjectType,
+		SubjectObjectID:   r.SubjectID,
+		SubjectRelation:   r.SubjectRelation,
+		CaveatName:        r.CaveatName,
+	}
+	if !r.Expiration.IsZero() {
+		expiration := r.Expiration.UTC()
+		record.Expiration = &expiration
+	}
+
+	if r.HasCaveat() {
+		caveatContext, err := structpb.NewStruct(r.CaveatContext)
+		if err != nil {
+			return fmt.Errorf("invalid caveat context: %w", err)
+		}
+
+		if record.CaveatContext, err = proto.Marshal(caveatContext); err != nil {
+			return fmt.Errorf("failed to encode caveat context: %w", err)
+		}
+	}
+
+	return e.enc.Encode(record)
+}
+
+// Close flushes any buffered relationships and finishes the backup.
+//
+// It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	return e.enc.Close()
+}
+
+// Decoder reads a backup in the format used by `zed backup`.
+type Decoder struct {
+	dec      *ocf.Decoder
+	schema   string
+	revision string
+}
+
+// NewDecoder reads the header and schema of a backup.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	dec, err := ocf.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	if !dec.HasNext() {
+		if err := dec.Error(); err != nil {
+			return nil, fmt.Errorf("failed to read backup: %w", err)
+		}
+		return nil, ErrMissingSchema
+	}
+
+	record, err := decodeRecord(dec)
+	if err != nil {
+		return nil, err
+	}
+	schema, ok := record[schemaV1Key]
+	if !ok {
+		return nil, ErrMissingSchema
+	}
+
+	return &Decoder{
+		dec:      dec,
+		schema:   stringField(schema, "schema_text"),
+		revision: string(dec.Metadata()[metadataKeyZT]),
+	}, nil
+}
+
+// Schema returns the schema text stored in the backup.
+func (d *Decoder) Schema() string { return d.schema }
+
+// Revision returns the revision at which the backup was taken.
+func (d *Decoder) Revision() string { return d.revision }
+
+// Next returns the next relationship in the backup or io.EOF once all
+// relationships have been read.
+func (d *Decoder) Next() (rel.Relationship, error) {
+	if !d.dec.HasNext() {
+		if err := d.dec.Error(); err != nil {
+			return rel.Relationship{}, fmt.Errorf("failed to read backup: %w", err)
+		}
+		return rel.Relationship{}, io.EOF
+	}
+
+	record, err := decodeRecord(d.dec)
+	if err != nil {
+		return rel.Relationship{}, err
+	}
+	fields, ok := record[relationshipV1Key]
+	if !ok {
+		return rel.Relationship{}, fmt.Errorf("unexpected record in backup: %v", record)
+	}
+
+	r := rel.Relationship{
+		ResourceType:     stringField(fields, "object_type"),
+		ResourceID:       stringField(fields, "object_id"),
+		ResourceRelation: stringField(fields, "relation"),
+		SubjectType:      stringField(fields, "subject_object_type"),
+		SubjectID:        stringField(fields, "subject_object_id"),
+		SubjectRelation:  stringField(fields, "subject_relation"),
+		CaveatName:       stringField(fields, "caveat_name"),
+	}
+
+	if encoded, _ := fields["caveat_context"].([]byte); r.CaveatName != "" && len(encoded) > 0 {
+		var caveatContext structpb.Struct
+		if err := proto.Unmarshal(encoded, &caveatContext); err != nil {
+			return rel.Relationship{}, fmt.Errorf("failed to decode caveat context: %w", err)
+		}
+		r.CaveatContext = caveatContext.AsMap()
+	}
+	if expiration, ok := fields["expiration"].(time.Time); ok {
+		r.Expiration = expiration
+	}
+	return r, nil
+}
+
+// decodeRecord decodes the next union value into its fields keyed by the
+// full name of its record type.
+//
+// Records are decoded generically because the decoder always uses the
+// global avro configuration, where the records may not be registered.
+func decodeRecord(dec *ocf.Decoder) (map[string]map[string]any, error) {
+	var record map[string]any
+	if err := dec.Decode(&record); err != nil {
+		return nil, fmt.Errorf("failed to decode backup record: %w", err)
+	}
+
+	decoded := make(map[string]map[string]any, len(record))
+	for name, value := range record {
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s record in backup: %T", name, value)
+		}
+		decoded[name] = fields
+	}
+	return decoded, nil
+}
+
+func stringField(fields map[string]any, name string) string {
+	s, _ := fields[name].(string)
+	return s
+}
@@ -0,0 +1,153 @@
+package backup_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+
+	"github.com/jzelinskie/gochugaru/backup"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+func TestEncoderDecoderRoundTrip(t *testing.T) {
+	schema := "definition user {}\n\ndefinition document {\n\trelation viewer: user\n}"
+	rels := []rel.Relationship{
+		rel.MustFromTriple("document:example", "viewer", "user:jzelinskie"),
+		rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithCaveat("is_weekday", map[string]any{"day": "monday"}),
+		rel.MustFromTriple("document:example", "viewer", "group:eng#member"),
+		rel.MustFromTriple("document:expiring", "viewer", "user:jzelinskie").WithExpiration(time.Date(2030, 1, 2, 3, 4, 5, 6000, time.UTC)),
+	}
+
+	var buf bytes.Buffer
+	enc, err := backup.NewEncoder(&buf, schema, "revision")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rels {
+		if err := enc.Append(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := backup.NewDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if dec.Schema() != schema {
+		t.Fatalf("unexpected schema: %q", dec.Schema())
+	} else if dec.Revision() != "revision" {
+		t.Fatalf("unexpected revision: %q", dec.Revision())
+	}
+
+	var decoded []rel.Relationship
+	for {
+		r, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, r)
+	}
+	if !reflect.DeepEqual(decoded, rels) {
+		t.Fatalf("unexpected relationships:\n%v\nexpected:\n%v", decoded, rels)
+	}
+}
+
+// oldAvroSchema is the format of backups written before relationships could
+// expire.
+const oldAvroSchema = `[
+	{
+		"type": "record",
+		"name": "RelationshipV1",
+		"namespace": "com.authzed.spicedb.backup",
+		"fields": [
+			{"name": "object_type", "type": "string"},
+			{"name": "object_id", "type": "string"},
+			{"name": "relation", "type": "string"},
+			{"name": "subject_object_type", "type": "string"},
+			{"name": "subject_object_id", "type": "string"},
+			{"name": "subject_relation", "type": "string"},
+			{"name": "caveat_name", "type": "string"},
+			{"name": "caveat_context", "type": "bytes"}
+		]
+	},
+	{
+		"type": "record",
+		"name": "SchemaV1",
+		"namespace": "com.authzed.spicedb.backup",
+		"fields": [
+			{"name": "schema_text", "type": "string"}
+		]
+	}
+]`
+
+type oldRelationshipV1 struct {
+	ObjectType        string `avro:"object_type"`
+	ObjectID          string `avro:"object_id"`
+	Relation          string `avro:"relation"`
+	SubjectObjectType string `avro:"subject_object_type"`
+	SubjectObjectID   string `avro:"subject_object_id"`
+	SubjectRelation   string `avro:"subject_relation"`
+	CaveatName        string `avro:"caveat_name"`
+	CaveatContext     []byte `avro:"caveat_context"`
+}
+
+type oldSchemaV1 struct {
+	SchemaText string `avro:"schema_text"`
+}
+
+func TestDecoderWithoutExpiration(t *testing.T) {
+	api := avro.Config{}.Freeze()
+	api.Register("com.authzed.spicedb.backup.RelationshipV1", oldRelationshipV1{})
+	api.Register("com.authzed.spicedb.backup.SchemaV1", oldSchemaV1{})
+
+	var buf bytes.Buffer
+	enc, err := ocf.NewEncoder(oldAvroSchema, &buf,
+		ocf.WithMetadata(map[string][]byte{"com.authzed.spicedb.zedtoken.v1": []byte("revision")}),
+		ocf.WithEncodingConfig(api),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(oldSchemaV1{SchemaText: "definition user {}"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(oldRelationshipV1{
+		ObjectType:        "document",
+		ObjectID:          "example",
+		Relation:          "viewer",
+		SubjectObjectType: "user",
+		SubjectObjectID:   "jzelinskie",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := backup.NewDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if dec.Schema() != "definition user {}" || dec.Revision() != "revision" {
+		t.Fatalf("unexpected header: %q at %q", dec.Schema(), dec.Revision())
+	}
+
+	r, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	} else if expected := rel.MustFromTriple("document:example", "viewer", "user:jzelinskie"); !reflect.DeepEqual(r, expected) {
+		t.Fatalf("unexpected relationship: %v", r)
+	}
+	if _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
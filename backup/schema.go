@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// normalizePrefix returns the prefix including its trailing separator.
+func normalizePrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	return strings.TrimSuffix(prefix, "/") + "/"
+}
+
+// schemaToken is an identifier or brace found in schema text outside of
+// comments and string literals.
+type schemaToken struct {
+	start, end int
+
+	// depth is the number of braces enclosing the token, including the token
+	// itself if it is an opening brace.
+	depth int
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || r == '/' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// scanSchema calls the provided function for each identifier and brace in the
+// schema.
+//
+// Comments and string literals (which can occur in caveat expressions) are
+// skipped.
+func scanSchema(schema string, fn func(tok schemaToken)) {
+	depth := 0
+	for i := 0; i < len(schema); {
+		r, size := utf8.DecodeRuneInString(schema[i:])
+		rest := schema[i:]
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				return
+			}
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return
+			}
+			i += end + 4
+		case r == '"' || r == '\'':
+			i += size
+			for i < len(schema) && schema[i] != byte(r) {
+				if schema[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+		case r == '{':
+			depth++
+			fn(schemaToken{i, i + size, depth})
+			i += size
+		case r == '}':
+			fn(schemaToken{i, i + size, depth})
+			depth--
+			i += size
+		case isIdentifierRune(r):
+			start := i
+			for i < len(schema) {
+				r, size := utf8.DecodeRuneInString(schema[i:])
+				if !isIdentifierRune(r) {
+					break
+				}
+				i += size
+			}
+			fn(schemaToken{start, i, depth})
+		default:
+			i += size
+		}
+	}
+}
+
+// rewriteSchemaPrefix replaces the prefix of every object type and caveat
+// name in the schema.
+func rewriteSchemaPrefix(schema, from, to string) string {
+	var b strings.Builder
+	last := 0
+	scanSchema(schema, func(tok schemaToken) {
+		if strings.HasPrefix(schema[tok.start:tok.end], from) {
+			b.WriteString(schema[last:tok.start])
+			b.WriteString(to)
+			last = tok.start + len(from)
+		}
+	})
+	b.WriteString(schema[last:])
+	return b.String()
+}
+
+// filterSchemaPrefix removes the definitions and caveats whose names do not
+// have the provided prefix along with any comments that precede them.
+func filterSchemaPrefix(schema, prefix string) string {
+	var b strings.Builder
+	itemStart := 0
+	expectName, keep := false, true
+	scanSchema(schema, func(tok schemaToken) {
+		word := schema[tok.start:tok.end]
+		switch {
+		case expectName:
+			keep = strings.HasPrefix(word, prefix)
+			expectName = false
+		case tok.depth == 1 && word == "}":
+			// Each item ends with the brace closing its body; everything up
+			// to the next item (e.g. its comments) belongs to the next item.
+			if keep {
+				b.WriteString(schema[itemStart:tok.end])
+			}
+			itemStart = tok.end
+		case tok.depth == 0 && (word == "definition" || word == "caveat"):
+			expectName = true
+		}
+	})
+	b.WriteString(schema[itemStart:])
+	return strings.TrimLeft(b.String(), "\r\n")
+}
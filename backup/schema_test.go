@@ -0,0 +1,71 @@
+package backup
+
+import "testing"
+
+const tenantSchema = `/** a user */
+definition tenant1/user {}
+
+caveat tenant1/is_weekday(day string) {
+	day != "tenant2/sunday" // {
+}
+
+// a document
+definition tenant1/document {
+	relation viewer: tenant1/user | tenant2/user with tenant1/is_weekday
+	permission view = viewer
+}
+
+definition tenant2/user {}
+`
+
+func TestFilterSchemaPrefix(t *testing.T) {
+	got := filterSchemaPrefix(tenantSchema, normalizePrefix("tenant2"))
+	if expected := "definition tenant2/user {}\n"; got != expected {
+		t.Fatalf("unexpected schema: %q", got)
+	}
+
+	got = filterSchemaPrefix(tenantSchema, normalizePrefix("tenant1"))
+	expected := `/** a user */
+definition tenant1/user {}
+
+caveat tenant1/is_weekday(day string) {
+	day != "tenant2/sunday" // {
+}
+
+// a document
+definition tenant1/document {
+	relation viewer: tenant1/user | tenant2/user with tenant1/is_weekday
+	permission view = viewer
+}
+`
+	if got != expected {
+		t.Fatalf("unexpected schema: %q", got)
+	}
+}
+
+func TestRewriteSchemaPrefix(t *testing.T) {
+	got := rewriteSchemaPrefix(tenantSchema, normalizePrefix("tenant1"), normalizePrefix("tenant3"))
+	expected := `/** a user */
+definition tenant3/user {}
+
+caveat tenant3/is_weekday(day string) {
+	day != "tenant2/sunday" // {
+}
+
+// a document
+definition tenant3/document {
+	relation viewer: tenant3/user | tenant2/user with tenant3/is_weekday
+	permission view = viewer
+}
+
+definition tenant2/user {}
+`
+	if got != expected {
+		t.Fatalf("unexpected schema: %q", got)
+	}
+
+	got = rewriteSchemaPrefix("definition tenant1/user {}", normalizePrefix("tenant1/"), "")
+	if expected := "definition user {}"; got != expected {
+		t.Fatalf("unexpected schema: %q", got)
+	}
+}
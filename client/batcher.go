@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"math/bits"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// BatchStats is a snapshot of the metrics collected while automatically
+// batching checks.
+type BatchStats struct {
+	// Batches is the total number of batches that have been dispatched.
+	Batches uint64
+
+	// Items is the total number of checks that have been dispatched.
+	Items uint64
+
+	// Sizes is a histogram of batch sizes where Sizes[i] counts the batches
+	// with at most 2^i items. The last bucket also counts any larger batches.
+	Sizes [12]uint64
+}
+
+// checkBatcher collects concurrent single checks that share a consistency
+// strategy into a single batched check.
+type checkBatcher struct {
+	client  *Client
+	window  time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	pending map[string]*pendingBatch
+	stats   BatchStats
+}
+
+type pendingBatch struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	cs      *consistency.Strategy
+	items   []rel.Interface
+	waiters []chan CheckResult
+	timer   *time.Timer
+
+	// deadline is the latest deadline of the callers in the batch and is zero
+	// if any of them has no deadline.
+	deadline time.Time
+
+	// waiting is the number of callers still waiting for the batch; the batch
+	// is abandoned once it reaches zero.
+	waiting int
+
+	// md is the outgoing metadata shared by every caller in the batch.
+	md metadata.MD
+}
+
+func newCheckBatcher(c *Client, window time.Duration, maxSize int) *checkBatcher {
+	return &checkBatcher{
+		client:  c,
+		window:  window,
+		maxSize: max(maxSize, 1),
+		pending: make(map[string]*pendingBatch),
+	}
+}
+
+// check enqueues a relationship into the pending batch for its consistency
+// strategy and waits for the result of the batch.
+func (b *checkBatcher) check(ctx context.Context, cs *consistency.Strategy, r rel.Interface) (CheckResult, error) {
+	key, err := consistencyKey(cs)
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	waiter := make(chan CheckResult, 1)
+
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	if !ok {
+		// The batch is shared by many callers, so it must not be cancelled
+		// along with, or carry the values of, the caller that happened to
+		// create it.
+		batchCtx, cancel := context.WithCancel(context.Background())
+		batch = &pendingBatch{ctx: batchCtx, cancel: cancel, cs: cs}
+		batch.timer = time.AfterFunc(b.window, func() { b.flush(key, batch) })
+		b.pending[key] = batch
+	}
+	deadline, hasDeadline := ctx.Deadline()
+	md, _ := metadata.FromOutgoingContext(ctx)
+	switch {
+	case len(batch.items) == 0:
+		batch.deadline = deadline
+		batch.md = md
+	case !hasDeadline:
+		batch.deadline = time.Time{}
+	case !batch.deadline.IsZero() && deadline.After(batch.deadline):
+		batch.deadline = deadline
+	}
+	if len(batch.items) > 0 {
+		batch.md = sharedMetadata(batch.md, md)
+	}
+	batch.waiting++
+	batch.items = append(batch.items, r)
+	batch.waiters = append(batch.waiters, waiter)
+	full := len(batch.items) >= b.maxSize
+	if full {
+		batch.timer.Stop()
+		delete(b.pending, key)
+	}
+	b.mu.Unlock()
+
+	if full {
+		go b.dispatch(batch)
+	}
+
+	select {
+	case result := <-waiter:
+		return result, result.Err
+	case <-ctx.Done():
+		b.leave(key, batch)
+		return CheckResult{}, ctx.Err()
+	}
+}
+
+// leave removes a caller that stopped waiting from the batch and abandons the
+// batch if it was the last one.
+func (b *checkBatcher) leave(key string, batch *pendingBatch) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch.waiting--
+	if batch.waiting > 0 {
+		return
+	}
+	if b.pending[key] == batch {
+		batch.timer.Stop()
+		delete(b.pending, key)
+	}
+	batch.cancel()
+}
+
+// flush dispatches the batch if it hasn't already been dispatched for being
+// full.
+func (b *checkBatcher) flush(key string, batch *pendingBatch) {
+	b.mu.Lock()
+	if b.pending[key] != batch {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	b.mu.Unlock()
+
+	b.dispatch(batch)
+}
+
+func (b *checkBatcher) dispatch(batch *pendingBatch) {
+	b.mu.Lock()
+	size := uint64(len(batch.items))
+	b.stats.Batches++
+	b.stats.Items += size
+	b.stats.Sizes[min(bits.Len64(size-1), len(b.stats.Sizes)-1)]++
+	b.mu.Unlock()
+
+	defer batch.cancel()
+	ctx := batch.ctx
+	if len(batch.md) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, batch.md)
+	}
+	if !batch.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, batch.deadline)
+		defer cancel()
+	}
+
+	results, err := b.client.checkChunked(ctx, batch.cs, batch.items, nil)
+	for i, waiter := range batch.waiters {
+		if err != nil {
+			waiter <- CheckResult{Err: err}
+			continue
+		}
+		waiter <- results[i]
+	}
+}
+
+// sharedMetadata returns the metadata whose values are identical in both.
+func sharedMetadata(a, b metadata.MD) metadata.MD {
+	shared := make(metadata.MD, len(a))
+	for key, values := range a {
+		if slices.Equal(values, b[key]) {
+			shared[key] = values
+		}
+	}
+	return shared
+}
+
+func (b *checkBatcher) snapshot() BatchStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// consistencyKey returns a string that uniquely identifies a consistency
+// strategy.
+func consistencyKey(cs *consistency.Strategy) (string, error) {
+	key, err := proto.MarshalOptions{Deterministic: true}.Marshal(cs.V1Consistency)
+	return string(key), err
+}
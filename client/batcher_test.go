@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+)
+
+func TestCheckBatchingFanOut(t *testing.T) {
+	srv := &checkServer{}
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) }, WithCheckBatching(time.Minute, 4))
+
+	subjects := []string{"allowed", "denied", "conditional", "invalid"}
+	rs := checkRels(subjects...)
+	results := make([]CheckResult, len(rs))
+	errs := make([]error, len(rs))
+
+	var wg sync.WaitGroup
+	for i, r := range rs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = c.CheckOneDetailed(context.Background(), consistency.MinLatency(), r)
+		}()
+	}
+	wg.Wait()
+
+	for i, subject := range subjects {
+		switch subject {
+		case "allowed":
+			if !results[i].Allowed() || errs[i] != nil {
+				t.Fatalf("unexpected result for %s: %+v %v", subject, results[i], errs[i])
+			}
+		case "denied":
+			if results[i].Permissionship != NoPermission || errs[i] != nil {
+				t.Fatalf("unexpected result for %s: %+v %v", subject, results[i], errs[i])
+			}
+		case "conditional":
+			if !results[i].IsConditional() || results[i].MissingCaveatFields[0] != "2" || errs[i] != nil {
+				t.Fatalf("unexpected result for %s: %+v %v", subject, results[i], errs[i])
+			}
+		case "invalid":
+			if errs[i] == nil || !errors.Is(errs[i], results[i].Err) {
+				t.Fatalf("expected an error for %s, got %+v", subject, results[i])
+			}
+		}
+	}
+
+	if requests := srv.requests(); requests != 1 {
+		t.Fatalf("expected a single batch, got %d", requests)
+	} else if stats := c.CheckBatchingStats(); stats.Batches != 1 || stats.Items != 4 || stats.Sizes[2] != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// hangingCheckServer blocks every check until it is cancelled.
+type hangingCheckServer struct {
+	v1.UnimplementedPermissionsServiceServer
+
+	deadlines chan time.Time
+	once      sync.Once
+	cancelled chan struct{}
+}
+
+func (s *hangingCheckServer) CheckBulkPermissions(ctx context.Context, _ *v1.CheckBulkPermissionsRequest) (*v1.CheckBulkPermissionsResponse, error) {
+	deadline, _ := ctx.Deadline()
+	s.deadlines <- deadline
+	<-ctx.Done()
+	s.once.Do(func() { close(s.cancelled) })
+	return nil, ctx.Err()
+}
+
+func TestCheckBatchingCancellation(t *testing.T) {
+	cases := []struct {
+		name        string
+		timeout     time.Duration
+		expectedErr error
+	}{
+		{"cancelled callers", 0, context.Canceled},
+		{"caller deadlines", 100 * time.Millisecond, context.DeadlineExceeded},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := &hangingCheckServer{deadlines: make(chan time.Time, 1), cancelled: make(chan struct{})}
+			c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) }, WithCheckBatching(time.Millisecond, 10))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i, r := range checkRels("allowed", "denied") {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = c.CheckOneDetailed(ctx, consistency.MinLatency(), r)
+				}()
+			}
+
+			deadline := <-srv.deadlines
+			if tc.timeout == 0 {
+				cancel()
+			} else if deadline.After(time.Now().Add(time.Second)) {
+				t.Fatalf("batch did not inherit the deadline of its callers: %v", deadline)
+			}
+			wg.Wait()
+
+			for _, err := range errs {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			select {
+			case <-srv.cancelled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("batch was not cancelled after every caller stopped waiting")
+			}
+		})
+	}
+}
+
+// metadataCheckServer records the metadata of every check it answers.
+type metadataCheckServer struct {
+	checkServer
+
+	received chan metadata.MD
+}
+
+func (s *metadataCheckServer) CheckBulkPermissions(ctx context.Context, req *v1.CheckBulkPermissionsRequest) (*v1.CheckBulkPermissionsResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.received <- md
+	return s.checkServer.CheckBulkPermissions(ctx, req)
+}
+
+func TestCheckBatchingMetadata(t *testing.T) {
+	srv := &metadataCheckServer{received: make(chan metadata.MD, 1)}
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) }, WithCheckBatching(time.Minute, 2))
+
+	var wg sync.WaitGroup
+	for i, r := range checkRels("allowed", "denied") {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "tenant", "a", "trace", string(rune('a'+i)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.CheckOneDetailed(ctx, consistency.MinLatency(), r); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	md := <-srv.received
+	if tenant := md.Get("tenant"); len(tenant) != 1 || tenant[0] != "a" {
+		t.Fatalf("expected the shared metadata to be forwarded, got %v", md)
+	} else if trace := md.Get("trace"); len(trace) != 0 {
+		t.Fatalf("expected metadata that differs between callers to be dropped, got %v", trace)
+	}
+}
@@ -0,0 +1,416 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// CheckCache is a size-bounded, in-process LRU cache of check results.
+//
+// Results are keyed by the relationship, its caveat context, and the
+// revision they were requested at:
+//
+//   - Snapshot results are immutable and are cached until they expire.
+//   - MinLatency and AtLeast results are only served while the client is
+//     watching for updates (see Client.WatchCheckCache) and are invalidated by
+//     any update or any write made through the client. To guarantee they are
+//     not older than the last update that was handled, these checks are
+//     performed at least as fresh as its revision. ZedTokens cannot be
+//     compared, so AtLeast results are only cached when their revision is
+//     exactly that revision.
+//   - Writes made through the client are not yet reflected by the revision
+//     of the last handled update, so these checks are performed with full
+//     consistency until the watch has handled the revision of every write.
+//     Schema writes, imports, deletions that fail, and writes that change
+//     nothing cannot be matched with an update in the watch, so they cause
+//     full consistency until the watch is restarted.
+//   - Full consistency results are never cached.
+//
+// A CheckCache is safe for concurrent use.
+type CheckCache struct {
+	mu       sync.Mutex
+	size     int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	lru      *list.List
+	watching bool
+
+	// revision is the revision of the last update handled while watching.
+	revision string
+
+	// writes are the revisions of writes made through the client that have
+	// not been handled while watching. An empty revision is never handled.
+	writes map[string]struct{}
+
+	// generation is incremented on every invalidation so that results of
+	// checks that were in-flight during an invalidation are not cached.
+	generation uint64
+}
+
+// cacheState is the state of a cache when a check began; results are only
+// cached if the state is unchanged when the check completes.
+type cacheState struct {
+	generation uint64
+	revision   string
+	writes     bool
+}
+
+type cacheEntry struct {
+	key      string
+	result   CheckResult
+	expires  time.Time
+	pinned   bool
+	revision string
+}
+
+// NewCheckCache creates a cache holding at most size results each for at
+// most the provided TTL.
+//
+// A zero TTL never expires results.
+func NewCheckCache(size int, ttl time.Duration) *CheckCache {
+	return &CheckCache{
+		size:    max(size, 1),
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		writes:  make(map[string]struct{}),
+	}
+}
+
+// maxUnhandledWrites bounds the number of write revisions tracked until they
+// are handled while watching; any further write is treated as never handled.
+const maxUnhandledWrites = 1_024
+
+// Invalidate removes all of the results that could have been affected by a
+// write.
+func (cc *CheckCache) Invalidate() {
+	if cc == nil {
+		return
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.invalidateLocked()
+}
+
+// wrote invalidates the cache after a write made through the client at the
+// provided revision, which is empty if it is unknown.
+//
+// Until the revision has been handled while watching, results are checked
+// with full consistency so that they reflect the write.
+func (cc *CheckCache) wrote(revision string) {
+	if cc == nil {
+		return
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if len(cc.writes) >= maxUnhandledWrites {
+		revision = ""
+	}
+	cc.writes[revision] = struct{}{}
+	cc.invalidateLocked()
+}
+
+func (cc *CheckCache) invalidateLocked() {
+	cc.generation++
+	for e := cc.lru.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*cacheEntry); !entry.pinned {
+			cc.lru.Remove(e)
+			delete(cc.entries, entry.key)
+		}
+		e = next
+	}
+}
+
+// Len returns the number of results currently cached.
+func (cc *CheckCache) Len() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.lru.Len()
+}
+
+// setWatching enables serving unpinned results that were checked at or after
+// the provided revision.
+//
+// An empty revision disables serving them.
+func (cc *CheckCache) setWatching(revision string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.watching = revision != ""
+	cc.revision = revision
+	clear(cc.writes)
+	cc.invalidateLocked()
+}
+
+// handled records that the updates through the provided revision have been
+// handled, invalidating any results checked before them.
+func (cc *CheckCache) handled(revision string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.revision = revision
+	delete(cc.writes, revision)
+	cc.invalidateLocked()
+}
+
+// state must be read before performing a check whose result will be cached.
+func (cc *CheckCache) state() cacheState {
+	if cc == nil {
+		return cacheState{}
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.stateLocked()
+}
+
+func (cc *CheckCache) stateLocked() cacheState {
+	return cacheState{generation: cc.generation, revision: cc.revision, writes: len(cc.writes) > 0}
+}
+
+// strategy returns the consistency strategy a check must be performed with
+// for its result to be cached.
+//
+// Checks that would be cached without a revision are performed at least as
+// fresh as the last update that was handled, or with full consistency if
+// writes made through the client have not been handled yet.
+func (st cacheState) strategy(cs *consistency.Strategy) *consistency.Strategy {
+	switch req := cs.V1Consistency.GetRequirement().(type) {
+	case *v1.Consistency_MinimizeLatency:
+	case *v1.Consistency_AtLeastAsFresh:
+		if req.AtLeastAsFresh.GetToken() != st.revision {
+			return cs
+		}
+	default:
+		return cs
+	}
+
+	switch {
+	case st.revision == "":
+		return cs
+	case st.writes:
+		return consistency.Full()
+	}
+	return consistency.AtLeast(st.revision)
+}
+
+func (cc *CheckCache) get(cs *consistency.Strategy, r rel.Interface) (CheckResult, bool) {
+	if cc == nil {
+		return CheckResult{}, false
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	key, pinned, ok := cacheKey(cs, r, cc.revision)
+	if !ok || (!pinned && !cc.watching) {
+		return CheckResult{}, false
+	}
+
+	e, ok := cc.entries[key]
+	if !ok {
+		return CheckResult{}, false
+	}
+
+	entry := e.Value.(*cacheEntry)
+	if !pinned && entry.revision != cc.revision {
+		cc.lru.Remove(e)
+		delete(cc.entries, key)
+		return CheckResult{}, false
+	}
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		cc.lru.Remove(e)
+		delete(cc.entries, key)
+		return CheckResult{}, false
+	}
+
+	cc.lru.MoveToFront(e)
+	return entry.result, true
+}
+
+// put caches the result of a check that was performed with the strategy
+// returned by state.strategy for the provided consistency strategy.
+func (cc *CheckCache) put(st cacheState, cs *consistency.Strategy, r rel.Interface, result CheckResult) {
+	if cc == nil || result.Err != nil {
+		return
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	key, pinned, ok := cacheKey(cs, r, st.revision)
+	if !ok {
+		return
+	}
+	if !pinned && (!cc.watching || st != cc.stateLocked()) {
+		return
+	}
+
+	var expires time.Time
+	if cc.ttl > 0 {
+		expires = time.Now().Add(cc.ttl)
+	}
+
+	if e, ok := cc.entries[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		entry.result, entry.expires, entry.revision = result, expires, st.revision
+		cc.lru.MoveToFront(e)
+		return
+	}
+
+	cc.entries[key] = cc.lru.PushFront(&cacheEntry{
+		key:      key,
+		result:   result,
+		expires:  expires,
+		pinned:   pinned,
+		revision: st.revision,
+	})
+	for cc.lru.Len() > cc.size {
+		oldest := cc.lru.Back()
+		cc.lru.Remove(oldest)
+		delete(cc.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cacheKey returns the key for a check and whether the result is pinned to a
+// specific revision.
+//
+// Unpinned results share a key because they are all checked at least as
+// fresh as the provided revision of the last handled update. AtLeast checks
+// for any other revision cannot be cached because it's unknown whether that
+// revision is newer.
+//
+// Checks that cannot be cached return false.
+func cacheKey(cs *consistency.Strategy, ir rel.Interface, handledRevision string) (key string, pinned, ok bool) {
+	var revision string
+	switch req := cs.V1Consistency.GetRequirement().(type) {
+	case *v1.Consistency_MinimizeLatency:
+		revision = "latest"
+	case *v1.Consistency_AtLeastAsFresh:
+		if handledRevision == "" || req.AtLeastAsFresh.GetToken() != handledRevision {
+			return "", false, false
+		}
+		revision = "latest"
+	case *v1.Consistency_AtExactSnapshot:
+		revision, pinned = "snapshot:"+req.AtExactSnapshot.GetToken(), true
+	default:
+		return "", false, false
+	}
+
+	r := ir.Relationship()
+	caveatContext, err := json.Marshal(r.CaveatContext)
+	if err != nil {
+		return "", false, false
+	}
+
+	return strings.Join([]string{
+		revision,
+		r.ResourceType,
+		r.ResourceID,
+		r.ResourceRelation,
+		r.SubjectType,
+		r.SubjectID,
+		r.SubjectRelation,
+		string(caveatContext),
+	}, "\x00"), pinned, true
+}
+
+// WatchCheckCache subscribes to the Watch API in order to invalidate the
+// check cache whenever relationships are updated.
+//
+// The watch begins at the revision of a fully consistent read of the schema
+// so that no update can be missed, and results that aren't pinned to a
+// snapshot are only served from the cache once the watch has been
+// established and until this function returns.
+//
+// This function blocks and can and should be cancelled via context.
+func (c *Client) WatchCheckCache(ctx context.Context) error {
+	if c.checkCache == nil {
+		return nil
+	}
+
+	_, revision, err := c.ReadSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.client.Watch(ctx, &v1.WatchRequest{
+		OptionalStartCursor: &v1.ZedToken{Token: revision},
+	})
+	if err != nil {
+		return err
+	}
+
+	c.checkCache.setWatching(revision)
+	defer c.checkCache.setWatching("")
+
+	for {
+		resp, err := stream.Recv()
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if len(resp.Updates) > 0 {
+			c.checkCache.handled(resp.ChangesThrough.GetToken())
+		}
+	}
+}
+
+// checkCached serves any cached results and only checks the remaining
+// relationships.
+func (c *Client) checkCached(ctx context.Context, cs *consistency.Strategy, rs []rel.Interface, stop func(CheckResult) bool) ([]CheckResult, error) {
+	if c.checkCache == nil {
+		return c.checkChunked(ctx, cs, rs, stop)
+	}
+
+	results := make([]CheckResult, len(rs))
+	var (
+		missIndexes []int
+		misses      []rel.Interface
+	)
+	for i, r := range rs {
+		if result, ok := c.checkCache.get(cs, r); ok {
+			results[i] = result
+			if stop != nil && stop(result) {
+				for j := range results {
+					if j != i {
+						results[j] = CheckResult{Err: errCheckSkipped}
+					}
+				}
+				return results, nil
+			}
+			continue
+		}
+		missIndexes = append(missIndexes, i)
+		misses = append(misses, r)
+	}
+	if len(misses) == 0 {
+		return results, nil
+	}
+
+	state := c.checkCache.state()
+	checked, err := c.checkChunked(ctx, state.strategy(cs), misses, stop)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range checked {
+		results[missIndexes[i]] = result
+		c.checkCache.put(state, cs, misses[i], result)
+	}
+	return results, nil
+}
@@ -0,0 +1,295 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+func TestCheckCache(t *testing.T) {
+	allowed := CheckResult{Permissionship: HasPermission}
+	first := rel.MustFromTriple("document:first", "viewer", "user:jzelinskie")
+	second := rel.MustFromTriple("document:second", "viewer", "user:jzelinskie")
+
+	t.Run("latest only while watching", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		cache.put(cache.state(), consistency.MinLatency(), first, allowed)
+		if _, ok := cache.get(consistency.MinLatency(), first); ok {
+			t.Fatal("served unpinned result while not watching")
+		}
+
+		cache.setWatching("rev1")
+		cache.put(cache.state(), consistency.MinLatency(), first, allowed)
+		if result, ok := cache.get(consistency.MinLatency(), first); !ok || !result.Allowed() {
+			t.Fatal("expected cached result")
+		}
+
+		cache.setWatching("")
+		if _, ok := cache.get(consistency.MinLatency(), first); ok {
+			t.Fatal("served unpinned result after watching stopped")
+		}
+	})
+
+	t.Run("latest is checked at the handled revision", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		if cs := cache.state().strategy(consistency.MinLatency()); !cs.V1Consistency.GetMinimizeLatency() {
+			t.Fatal("expected minimal latency while not watching")
+		}
+
+		cache.setWatching("rev1")
+		if cs := cache.state().strategy(consistency.MinLatency()); cs.V1Consistency.GetAtLeastAsFresh().GetToken() != "rev1" {
+			t.Fatalf("expected a check at least as fresh as the handled revision, got %v", cs.V1Consistency)
+		}
+	})
+
+	t.Run("at least only at the handled revision", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		cache.setWatching("rev1")
+		cache.put(cache.state(), consistency.AtLeast("rev0"), first, allowed)
+		if cache.Len() != 0 {
+			t.Fatal("cached result that could predate the handled revision")
+		}
+
+		cache.put(cache.state(), consistency.AtLeast("rev1"), first, allowed)
+		if _, ok := cache.get(consistency.AtLeast("rev1"), first); !ok {
+			t.Fatal("expected cached result")
+		}
+		if _, ok := cache.get(consistency.MinLatency(), first); !ok {
+			t.Fatal("expected result at the handled revision to be served for latest")
+		}
+	})
+
+	t.Run("invalidation keeps snapshots", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		cache.setWatching("rev1")
+		cache.put(cache.state(), consistency.MinLatency(), first, allowed)
+		cache.put(cache.state(), consistency.Snapshot("rev"), first, allowed)
+
+		cache.Invalidate()
+		if _, ok := cache.get(consistency.MinLatency(), first); ok {
+			t.Fatal("served invalidated result")
+		}
+		if _, ok := cache.get(consistency.Snapshot("rev"), first); !ok {
+			t.Fatal("expected snapshot result to survive invalidation")
+		}
+	})
+
+	t.Run("results from before an update are not cached", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		cache.setWatching("rev1")
+		state := cache.state()
+		cache.handled("rev2")
+		cache.put(state, consistency.MinLatency(), first, allowed)
+		if cache.Len() != 0 {
+			t.Fatal("cached result checked before the handled update")
+		}
+		if _, ok := cache.get(consistency.AtLeast("rev1"), first); ok {
+			t.Fatal("served result for a revision before the handled update")
+		}
+	})
+
+	t.Run("own writes are checked fully consistent until handled", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		cache.setWatching("rev1")
+		cache.wrote("rev2")
+		for _, cs := range []*consistency.Strategy{consistency.MinLatency(), consistency.AtLeast("rev1")} {
+			if !cache.state().strategy(cs).V1Consistency.GetFullyConsistent() {
+				t.Fatalf("expected full consistency before the write was handled for %v", cs.V1Consistency)
+			}
+		}
+		if cs := cache.state().strategy(consistency.AtLeast("rev0")); cs.V1Consistency.GetAtLeastAsFresh().GetToken() != "rev0" {
+			t.Fatalf("expected an uncacheable check to be unchanged, got %v", cs.V1Consistency)
+		}
+
+		cache.put(cache.state(), consistency.MinLatency(), first, allowed)
+		if _, ok := cache.get(consistency.MinLatency(), first); !ok {
+			t.Fatal("expected fully consistent result to be cached")
+		}
+
+		cache.handled("rev2")
+		if cs := cache.state().strategy(consistency.MinLatency()); cs.V1Consistency.GetAtLeastAsFresh().GetToken() != "rev2" {
+			t.Fatalf("expected a check at least as fresh as the handled write, got %v", cs.V1Consistency)
+		}
+	})
+
+	t.Run("unknown writes are checked fully consistent until restarted", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		cache.setWatching("rev1")
+		cache.wrote("")
+		cache.handled("rev2")
+		if !cache.state().strategy(consistency.MinLatency()).V1Consistency.GetFullyConsistent() {
+			t.Fatal("expected full consistency after a write of an unknown revision")
+		}
+
+		cache.setWatching("rev3")
+		if cs := cache.state().strategy(consistency.MinLatency()); cs.V1Consistency.GetAtLeastAsFresh().GetToken() != "rev3" {
+			t.Fatalf("expected a check at least as fresh as the restarted watch, got %v", cs.V1Consistency)
+		}
+	})
+
+	t.Run("stale generation is not cached", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		cache.setWatching("rev1")
+		state := cache.state()
+		cache.Invalidate()
+		cache.put(state, consistency.MinLatency(), first, allowed)
+		if cache.Len() != 0 {
+			t.Fatal("cached result from before invalidation")
+		}
+	})
+
+	t.Run("full consistency is never cached", func(t *testing.T) {
+		cache := NewCheckCache(10, 0)
+		cache.setWatching("rev1")
+		cache.put(cache.state(), consistency.Full(), first, allowed)
+		if cache.Len() != 0 {
+			t.Fatal("cached fully consistent result")
+		}
+	})
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		cache := NewCheckCache(1, 0)
+		cache.put(cacheState{}, consistency.Snapshot("rev"), first, allowed)
+		cache.put(cacheState{}, consistency.Snapshot("rev"), second, allowed)
+		if _, ok := cache.get(consistency.Snapshot("rev"), first); ok {
+			t.Fatal("expected first result to be evicted")
+		}
+		if _, ok := cache.get(consistency.Snapshot("rev"), second); !ok {
+			t.Fatal("expected second result to be cached")
+		}
+	})
+}
+
+// watchCacheServer serves a schema read at "rev1", writes at "rev2", and a
+// watch that sends an update at "rev2" once the updates channel is closed.
+type watchCacheServer struct {
+	v1.UnimplementedSchemaServiceServer
+	v1.UnimplementedWatchServiceServer
+	checkServer
+
+	startCursor chan string
+	update      chan struct{}
+
+	mu          sync.Mutex
+	consistency []*v1.Consistency
+}
+
+func (s *watchCacheServer) ReadSchema(context.Context, *v1.ReadSchemaRequest) (*v1.ReadSchemaResponse, error) {
+	return &v1.ReadSchemaResponse{ReadAt: &v1.ZedToken{Token: "rev1"}}, nil
+}
+
+func (s *watchCacheServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchServer) error {
+	s.startCursor <- req.OptionalStartCursor.GetToken()
+	select {
+	case <-s.update:
+	case <-stream.Context().Done():
+		return nil
+	}
+
+	if err := stream.Send(&v1.WatchResponse{
+		ChangesThrough: &v1.ZedToken{Token: "rev2"},
+		Updates: []*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: rel.MustFromTriple("document:0", "view", "user:allowed").V1Proto(),
+		}},
+	}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func (s *watchCacheServer) WriteRelationships(context.Context, *v1.WriteRelationshipsRequest) (*v1.WriteRelationshipsResponse, error) {
+	return &v1.WriteRelationshipsResponse{WrittenAt: &v1.ZedToken{Token: "rev2"}}, nil
+}
+
+func (s *watchCacheServer) CheckBulkPermissions(ctx context.Context, req *v1.CheckBulkPermissionsRequest) (*v1.CheckBulkPermissionsResponse, error) {
+	s.mu.Lock()
+	s.consistency = append(s.consistency, req.Consistency)
+	s.mu.Unlock()
+	return s.checkServer.CheckBulkPermissions(ctx, req)
+}
+
+func (s *watchCacheServer) checkedAtLeast() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var revisions []string
+	for _, cs := range s.consistency {
+		revisions = append(revisions, cs.GetAtLeastAsFresh().GetToken())
+	}
+	return revisions
+}
+
+func TestWatchCheckCache(t *testing.T) {
+	srv := &watchCacheServer{startCursor: make(chan string, 1), update: make(chan struct{})}
+	cache := NewCheckCache(10, 0)
+	c := newTestClient(t, func(s *grpc.Server) {
+		v1.RegisterSchemaServiceServer(s, srv)
+		v1.RegisterWatchServiceServer(s, srv)
+		v1.RegisterPermissionsServiceServer(s, srv)
+	}, WithCheckCache(cache))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- c.WatchCheckCache(ctx) }()
+
+	if cursor := <-srv.startCursor; cursor != "rev1" {
+		t.Fatalf("expected the watch to start at the schema revision, got %q", cursor)
+	}
+	waitForRevision(t, cache, "rev1")
+
+	r := checkRels("allowed")[0]
+	for i := 0; i < 2; i++ {
+		if _, err := c.CheckOne(ctx, consistency.MinLatency(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if revisions := srv.checkedAtLeast(); len(revisions) != 1 || revisions[0] != "rev1" {
+		t.Fatalf("expected a single check at the watched revision, got %v", revisions)
+	}
+
+	// The write is not reflected by the watched revision until it is handled.
+	if _, err := c.Write(ctx, &rel.Txn{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CheckOne(ctx, consistency.MinLatency(), r); err != nil {
+		t.Fatal(err)
+	}
+	if revisions := srv.checkedAtLeast(); len(revisions) != 2 || revisions[1] != "" {
+		t.Fatalf("expected a fully consistent check after the write, got %v", revisions)
+	}
+
+	close(srv.update)
+	waitForRevision(t, cache, "rev2")
+	if _, err := c.CheckOne(ctx, consistency.MinLatency(), r); err != nil {
+		t.Fatal(err)
+	}
+	if revisions := srv.checkedAtLeast(); len(revisions) != 3 || revisions[2] != "rev2" {
+		t.Fatalf("expected a check at the updated revision, got %v", revisions)
+	}
+
+	cancel()
+	if err := <-watchErr; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.get(consistency.MinLatency(), r); ok {
+		t.Fatal("served unpinned result after watching stopped")
+	}
+}
+
+func waitForRevision(t *testing.T, cache *CheckCache, revision string) {
+	t.Helper()
+	for start := time.Now(); cache.state().revision != revision; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timed out waiting for revision %q", revision)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/authzed/authzed-go/pkg/requestmeta"
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"golang.org/x/exp/slices"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// API identifies a SpiceDB API whose availability varies between versions of
+// SpiceDB.
+type API string
+
+// The APIs that are only available in some versions of SpiceDB.
+const (
+	APICheckBulkPermissions    API = "/authzed.api.v1.PermissionsService/CheckBulkPermissions"
+	APIBulkCheckPermission     API = "/authzed.api.v1.ExperimentalService/BulkCheckPermission"
+	APIImportBulkRelationships API = "/authzed.api.v1.PermissionsService/ImportBulkRelationships"
+	APIExportBulkRelationships API = "/authzed.api.v1.PermissionsService/ExportBulkRelationships"
+	APIBulkImportRelationships API = "/authzed.api.v1.ExperimentalService/BulkImportRelationships"
+	APIReflectSchema           API = "/authzed.api.v1.ExperimentalService/ExperimentalReflectSchema"
+	APIDiffSchema              API = "/authzed.api.v1.ExperimentalService/ExperimentalDiffSchema"
+	APIComputablePermissions   API = "/authzed.api.v1.ExperimentalService/ExperimentalComputablePermissions"
+	APIDependentRelations      API = "/authzed.api.v1.ExperimentalService/ExperimentalDependentRelations"
+)
+
+// UnsupportedAPIError is returned when SpiceDB does not support any of the
+// APIs that could be used to fulfill a request.
+type UnsupportedAPIError struct {
+	APIs []API
+
+	// ServerVersion is the version reported by SpiceDB, if it was reported.
+	ServerVersion string
+}
+
+func (e *UnsupportedAPIError) Error() string {
+	version := e.ServerVersion
+	if version == "" {
+		version = "unknown version"
+	}
+	return fmt.Sprintf("SpiceDB (%s) does not support any of the required APIs: %v", version, e.APIs)
+}
+
+// ServerInfo describes the capabilities of the SpiceDB server.
+type ServerInfo struct {
+	// Version is the version reported by SpiceDB.
+	//
+	// This is empty if SpiceDB didn't report its version.
+	Version string
+
+	// Unsupported are the APIs that SpiceDB has reported as unimplemented.
+	Unsupported []API
+}
+
+// Supports returns false if SpiceDB is known to not support the API.
+//
+// APIs that have not yet been used are assumed to be supported.
+func (s ServerInfo) Supports(api API) bool {
+	for _, unsupported := range s.Unsupported {
+		if unsupported == api {
+			return false
+		}
+	}
+	return true
+}
+
+// capabilities tracks what has been learned about the server from responses.
+type capabilities struct {
+	mu          sync.RWMutex
+	version     string
+	unsupported map[API]struct{}
+}
+
+func (cp *capabilities) info() ServerInfo {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	info := ServerInfo{Version: cp.version}
+	for api := range cp.unsupported {
+		info.Unsupported = append(info.Unsupported, api)
+	}
+	slices.Sort(info.Unsupported)
+	return info
+}
+
+func (cp *capabilities) supports(api API) bool {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	_, unsupported := cp.unsupported[api]
+	return !unsupported
+}
+
+func (cp *capabilities) knowsVersion() bool {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	return cp.version != ""
+}
+
+func (cp *capabilities) recordHeader(header metadata.MD) {
+	values := header.Get(string(responsemeta.ServerVersion))
+	if len(values) == 0 {
+		return
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.version = values[0]
+}
+
+// checkUnimplemented records the API as unsupported if the error reports it
+// as unimplemented.
+//
+// Returns true if the API was unimplemented.
+func (cp *capabilities) checkUnimplemented(api API, err error) bool {
+	if !isGrpcCode(err, codes.Unimplemented) {
+		return false
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.unsupported == nil {
+		cp.unsupported = make(map[API]struct{})
+	}
+	cp.unsupported[api] = struct{}{}
+	return true
+}
+
+// unsupportedErr converts an unimplemented error into an UnsupportedAPIError.
+func (cp *capabilities) unsupportedErr(err error, apis ...API) error {
+	unimplemented := false
+	for _, api := range apis {
+		unimplemented = cp.checkUnimplemented(api, err) || unimplemented
+	}
+	if !unimplemented {
+		return err
+	}
+	return &UnsupportedAPIError{APIs: apis, ServerVersion: cp.info().Version}
+}
+
+// unaryInterceptor requests and records the server version until it is known.
+func (cp *capabilities) unaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if cp.knowsVersion() {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	var header metadata.MD
+	ctx = requestmeta.AddRequestHeaders(ctx, requestmeta.RequestServerVersion)
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...)
+	cp.recordHeader(header)
+	return err
+}
+
+// streamInterceptor requests and records the server version until it is
+// known.
+func (cp *capabilities) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if cp.knowsVersion() {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+
+	ctx = requestmeta.AddRequestHeaders(ctx, requestmeta.RequestServerVersion)
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &versionRecordingStream{ClientStream: stream, cp: cp}, nil
+}
+
+type versionRecordingStream struct {
+	grpc.ClientStream
+	cp   *capabilities
+	once sync.Once
+}
+
+func (s *versionRecordingStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	s.once.Do(func() {
+		if header, herr := s.ClientStream.Header(); herr == nil {
+			s.cp.recordHeader(header)
+		}
+	})
+	return err
+}
+
+// ServerInfo returns what is known about the capabilities of SpiceDB.
+//
+// If the version of SpiceDB is not yet known, a cheap request is made in
+// order to detect it.
+func (c *Client) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	if !c.capabilities.knowsVersion() {
+		_, err := c.client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+		if err != nil && !isGrpcCode(err, codes.NotFound) {
+			return ServerInfo{}, err
+		}
+	}
+	return c.capabilities.info(), nil
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+type versionedSchemaServer struct {
+	v1.UnimplementedSchemaServiceServer
+}
+
+func (versionedSchemaServer) ReadSchema(ctx context.Context, _ *v1.ReadSchemaRequest) (*v1.ReadSchemaResponse, error) {
+	if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("io.spicedb.requestversion")) > 0 {
+		if err := grpc.SetHeader(ctx, metadata.Pairs(string(responsemeta.ServerVersion), "v1.29.0")); err != nil {
+			return nil, err
+		}
+	}
+	return &v1.ReadSchemaResponse{ReadAt: &v1.ZedToken{Token: "rev"}}, nil
+}
+
+func TestServerInfo(t *testing.T) {
+	c := newTestClient(t, func(srv *grpc.Server) { v1.RegisterSchemaServiceServer(srv, versionedSchemaServer{}) })
+
+	ctx := context.Background()
+	info, err := c.ServerInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if info.Version != "v1.29.0" {
+		t.Fatalf("unexpected version: %q", info.Version)
+	} else if !info.Supports(APIBulkCheckPermission) {
+		t.Fatal("expected unused APIs to be assumed supported")
+	}
+
+	_, err = c.CheckOne(ctx, consistency.MinLatency(), rel.MustFromTriple("document:example", "viewer", "user:jzelinskie"))
+	var unsupportedErr *UnsupportedAPIError
+	if !errors.As(err, &unsupportedErr) {
+		t.Fatalf("expected UnsupportedAPIError, got %v", err)
+	} else if unsupportedErr.ServerVersion != "v1.29.0" {
+		t.Fatalf("unexpected version in error: %q", unsupportedErr.ServerVersion)
+	}
+
+	info, err = c.ServerInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if info.Supports(APICheckBulkPermissions) || info.Supports(APIBulkCheckPermission) {
+		t.Fatal("expected unimplemented APIs to be unsupported")
+	}
+}
+
+type experimentalCheckServer struct {
+	v1.UnimplementedExperimentalServiceServer
+}
+
+func (experimentalCheckServer) BulkCheckPermission(_ context.Context, req *v1.BulkCheckPermissionRequest) (*v1.BulkCheckPermissionResponse, error) {
+	resp := &v1.BulkCheckPermissionResponse{CheckedAt: &v1.ZedToken{Token: "checked"}}
+	for range req.Items {
+		resp.Pairs = append(resp.Pairs, &v1.BulkCheckPermissionPair{
+			Response: &v1.BulkCheckPermissionPair_Item{Item: &v1.BulkCheckPermissionResponseItem{
+				Permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+			}},
+		})
+	}
+	return resp, nil
+}
+
+func TestCheckFallsBackToExperimental(t *testing.T) {
+	c := newTestClient(t, func(srv *grpc.Server) { v1.RegisterExperimentalServiceServer(srv, experimentalCheckServer{}) })
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		result, err := c.CheckOneDetailed(ctx, consistency.MinLatency(), rel.MustFromTriple("document:example", "viewer", "user:jzelinskie"))
+		if err != nil {
+			t.Fatal(err)
+		} else if !result.Allowed() {
+			t.Fatal("expected permission")
+		} else if result.CheckedAt != "checked" {
+			t.Fatalf("unexpected checked at: %q", result.CheckedAt)
+		}
+	}
+
+	if c.capabilities.supports(APICheckBulkPermissions) {
+		t.Fatal("expected CheckBulkPermissions to be unsupported")
+	} else if !c.capabilities.supports(APIBulkCheckPermission) {
+		t.Fatal("expected BulkCheckPermission to be supported")
+	}
+}
@@ -0,0 +1,455 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"golang.org/x/exp/slices"
+	"google.golang.org/grpc/status"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// Permissionship represents the state of a permission after it has been
+// checked.
+type Permissionship int
+
+const (
+	// NoPermission means that the subject definitively lacks the permission.
+	NoPermission Permissionship = iota
+
+	// HasPermission means that the subject definitively has the permission.
+	HasPermission
+
+	// ConditionalPermission means that the subject only has the permission if
+	// caveats that were missing context were to evaluate to true.
+	ConditionalPermission
+)
+
+func (p Permissionship) String() string {
+	switch p {
+	case NoPermission:
+		return "no_permission"
+	case HasPermission:
+		return "has_permission"
+	case ConditionalPermission:
+		return "conditional_permission"
+	}
+	return "unknown"
+}
+
+// CheckResult is the outcome of checking a single relationship.
+type CheckResult struct {
+	Permissionship Permissionship
+
+	// MissingCaveatFields contains the caveat context fields that would be
+	// required to fully evaluate a conditional result.
+	MissingCaveatFields []string
+
+	// CheckedAt is the revision (ZedToken) at which the check was evaluated.
+	//
+	// It can be used with consistency.AtLeast or consistency.Snapshot to
+	// perform subsequent requests at the same or a later revision.
+	CheckedAt string
+
+	// Err is set when this specific item failed to be checked.
+	//
+	// The error retains its gRPC status, so the code and details can be
+	// extracted with status.FromError.
+	Err error
+}
+
+// Allowed returns true only if the subject definitively has the permission.
+func (r CheckResult) Allowed() bool { return r.Permissionship == HasPermission }
+
+// IsConditional returns true if the result could not be fully evaluated due to
+// missing caveat context.
+func (r CheckResult) IsConditional() bool { return r.Permissionship == ConditionalPermission }
+
+// CheckItemError is returned when an individual item in a batched check
+// failed.
+type CheckItemError struct {
+	// Index is the position of the failed item in the checked relationships.
+	Index int
+	Err   error
+}
+
+func (e *CheckItemError) Error() string { return fmt.Sprintf("check item %d: %s", e.Index, e.Err) }
+func (e *CheckItemError) Unwrap() error { return e.Err }
+
+// CheckDetailed performs a batched permissions check for the provided
+// relationships returning results that distinguish conditional permissions.
+//
+// The results are aligned with the provided relationships. Failures of
+// individual items do not fail the whole batch, but are instead reported
+// through the Err field of their result.
+//
+// Large batches are split into chunks that are checked concurrently.
+func (c *Client) CheckDetailed(ctx context.Context, cs *consistency.Strategy, rs ...rel.Interface) ([]CheckResult, error) {
+	return c.checkCached(ctx, cs, rs, nil)
+}
+
+// errCheckSkipped is the error for items whose chunk was never checked
+// because the outcome was already decided by another chunk.
+var errCheckSkipped = errors.New("check skipped: outcome already determined")
+
+// checkChunked splits the relationships into chunks and checks them
+// concurrently, preserving the order of the results.
+//
+// If stop is non-nil, any remaining chunks are abandoned as soon as a result
+// satisfies it; their items are reported with errCheckSkipped.
+func (c *Client) checkChunked(ctx context.Context, cs *consistency.Strategy, rs []rel.Interface, stop func(CheckResult) bool) ([]CheckResult, error) {
+	if len(rs) <= c.checkChunkSize {
+		return c.checkBatch(ctx, cs, rs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results  = make([]CheckResult, len(rs))
+		sem      = make(chan struct{}, c.checkConcurrency)
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		stopped  bool
+		firstErr error
+	)
+	for start := 0; start < len(rs); start += c.checkChunkSize {
+		end := min(start+c.checkChunkSize, len(rs))
+
+		mu.Lock()
+		done := stopped || firstErr != nil
+		mu.Unlock()
+		if !done {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				done = true
+			}
+		}
+		if done {
+			for i := start; i < end; i++ {
+				results[i] = CheckResult{Err: errCheckSkipped}
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer func() { <-sem; wg.Done() }()
+
+			chunk, err := c.checkBatch(ctx, cs, rs[start:end])
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				for i := start; i < end; i++ {
+					results[i] = CheckResult{Err: errCheckSkipped}
+				}
+				if !stopped && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+
+			copy(results[start:end], chunk)
+			if stop != nil && slices.ContainsFunc(chunk, stop) {
+				stopped = true
+				cancel()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	switch {
+	case stopped:
+		return results, nil
+	case firstErr != nil:
+		return nil, firstErr
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	}
+	return results, nil
+}
+
+// checkBatch performs a single bulk check request for the provided
+// relationships.
+//
+// CheckBulkPermissions is used unless SpiceDB doesn't support it, in which
+// case the experimental BulkCheckPermission is used instead.
+func (c *Client) checkBatch(ctx context.Context, cs *consistency.Strategy, rs []rel.Interface) ([]CheckResult, error) {
+	items := make([]*v1.CheckBulkPermissionsRequestItem, 0, len(rs))
+	for _, r := range rs {
+		items = append(items, v1CheckItem(r))
+	}
+
+	if c.capabilities.supports(APICheckBulkPermissions) {
+		results, err := c.checkBulkPermissions(ctx, cs, items)
+		if !c.capabilities.checkUnimplemented(APICheckBulkPermissions, err) {
+			return results, err
+		}
+	}
+
+	results, err := c.bulkCheckPermission(ctx, cs, items)
+	if err != nil {
+		return nil, c.capabilities.unsupportedErr(err, APICheckBulkPermissions, APIBulkCheckPermission)
+	}
+	return results, nil
+}
+
+func (c *Client) checkBulkPermissions(ctx context.Context, cs *consistency.Strategy, items []*v1.CheckBulkPermissionsRequestItem) ([]CheckResult, error) {
+	var resp *v1.CheckBulkPermissionsResponse
+	if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
+		resp, cErr = c.client.CheckBulkPermissions(cCtx, &v1.CheckBulkPermissionsRequest{
+			Consistency: cs.V1Consistency,
+			Items:       items,
+		})
+		return cErr
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Pairs) != len(items) {
+		return nil, fmt.Errorf("expected %d check results, received %d", len(items), len(resp.Pairs))
+	}
+
+	checkedAt := resp.CheckedAt.GetToken()
+	results := make([]CheckResult, 0, len(resp.Pairs))
+	for _, pair := range resp.Pairs {
+		switch resp := pair.Response.(type) {
+		case *v1.CheckBulkPermissionsPair_Item:
+			results = append(results, checkResultFromV1(
+				resp.Item.Permissionship,
+				resp.Item.PartialCaveatInfo,
+				checkedAt,
+			))
+		case *v1.CheckBulkPermissionsPair_Error:
+			results = append(results, CheckResult{Err: status.ErrorProto(resp.Error)})
+		default:
+			results = append(results, CheckResult{Err: errors.New("missing check result")})
+		}
+	}
+	return results, nil
+}
+
+// bulkCheckPermission is the fallback for SpiceDB versions that predate
+// CheckBulkPermissions.
+func (c *Client) bulkCheckPermission(ctx context.Context, cs *consistency.Strategy, items []*v1.CheckBulkPermissionsRequestItem) ([]CheckResult, error) {
+	experimentalItems := make([]*v1.BulkCheckPermissionRequestItem, 0, len(items))
+	for _, item := range items {
+		experimentalItems = append(experimentalItems, &v1.BulkCheckPermissionRequestItem{
+			Resource:   item.Resource,
+			Permission: item.Permission,
+			Subject:    item.Subject,
+			Context:    item.Context,
+		})
+	}
+
+	var resp *v1.BulkCheckPermissionResponse
+	if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
+		resp, cErr = c.client.BulkCheckPermission(cCtx, &v1.BulkCheckPermissionRequest{
+			Consistency: cs.V1Consistency,
+			Items:       experimentalItems,
+		})
+		return cErr
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Pairs) != len(items) {
+		return nil, fmt.Errorf("expected %d check results, received %d", len(items), len(resp.Pairs))
+	}
+
+	checkedAt := resp.CheckedAt.GetToken()
+	results := make([]CheckResult, 0, len(resp.Pairs))
+	for _, pair := range resp.Pairs {
+		switch resp := pair.Response.(type) {
+		case *v1.BulkCheckPermissionPair_Item:
+			results = append(results, checkResultFromV1(
+				resp.Item.Permissionship,
+				resp.Item.PartialCaveatInfo,
+				checkedAt,
+			))
+		case *v1.BulkCheckPermissionPair_Error:
+			results = append(results, CheckResult{Err: status.ErrorProto(resp.Error)})
+		default:
+			results = append(results, CheckResult{Err: errors.New("missing check result")})
+		}
+	}
+	return results, nil
+}
+
+// itemErrors joins the errors of any failed results.
+func itemErrors(results []CheckResult) error {
+	var errs []error
+	for i, result := range results {
+		if result.Err != nil {
+			errs = append(errs, &CheckItemError{Index: i, Err: result.Err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckOneDetailed performs a permissions check for a single relationship
+// returning a result that distinguishes conditional permissions.
+//
+// If batching is enabled, concurrent calls are combined into batched checks.
+//
+// If deduplication is enabled, identical concurrent calls that don't require
+// full consistency are deduplicated into a single check.
+func (c *Client) CheckOneDetailed(ctx context.Context, cs *consistency.Strategy, r rel.Interface) (CheckResult, error) {
+	if c.flights == nil || cs.V1Consistency.GetFullyConsistent() {
+		return c.checkOne(ctx, cs, r)
+	}
+
+	key, err := flightKey("CheckOne", cs.V1Consistency, v1CheckItem(r))
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	result, err := c.flights.do(ctx, key, func(ctx context.Context) (any, error) {
+		return c.checkOne(ctx, cs, r)
+	})
+	if result == nil {
+		return CheckResult{}, err
+	}
+	return result.(CheckResult), err
+}
+
+func (c *Client) checkOne(ctx context.Context, cs *consistency.Strategy, r rel.Interface) (CheckResult, error) {
+	if c.checkBatcher != nil {
+		if result, ok := c.checkCache.get(cs, r); ok {
+			return result, nil
+		}
+
+		state := c.checkCache.state()
+		result, err := c.checkBatcher.check(ctx, state.strategy(cs), r)
+		c.checkCache.put(state, cs, r, result)
+		return result, err
+	}
+
+	results, err := c.CheckDetailed(ctx, cs, r)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	return results[0], results[0].Err
+}
+
+// CheckAnyDetailed returns HasPermission if any of the provided relationships
+// have access.
+//
+// If none have access, but some are conditional, the result is conditional on
+// the union of all of their missing caveat fields.
+//
+// Items that failed are only reported if no item has access.
+func (c *Client) CheckAnyDetailed(ctx context.Context, cs *consistency.Strategy, rs []rel.Interface) (CheckResult, error) {
+	results, err := c.checkCached(ctx, cs, rs, CheckResult.Allowed)
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	combined := CheckResult{Permissionship: NoPermission}
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		} else if combined.CheckedAt == "" {
+			combined.CheckedAt = result.CheckedAt
+		}
+
+		switch result.Permissionship {
+		case HasPermission:
+			return result, nil
+		case ConditionalPermission:
+			combined.Permissionship = ConditionalPermission
+			combined.MissingCaveatFields = appendMissing(combined.MissingCaveatFields, result.MissingCaveatFields)
+		}
+	}
+	return combined, itemErrors(results)
+}
+
+// CheckAllDetailed returns HasPermission if all of the provided relationships
+// have access.
+//
+// If none lack access, but some are conditional, the result is conditional on
+// the union of all of their missing caveat fields.
+//
+// Items that failed are only reported if no item lacks access, in which case
+// the result is NoPermission because not every item could be checked.
+func (c *Client) CheckAllDetailed(ctx context.Context, cs *consistency.Strategy, rs []rel.Interface) (CheckResult, error) {
+	results, err := c.checkCached(ctx, cs, rs, lacksPermission)
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	combined := CheckResult{Permissionship: HasPermission}
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		} else if combined.CheckedAt == "" {
+			combined.CheckedAt = result.CheckedAt
+		}
+
+		switch result.Permissionship {
+		case NoPermission:
+			return result, nil
+		case ConditionalPermission:
+			combined.Permissionship = ConditionalPermission
+			combined.MissingCaveatFields = appendMissing(combined.MissingCaveatFields, result.MissingCaveatFields)
+		}
+	}
+
+	if err := itemErrors(results); err != nil {
+		return CheckResult{Permissionship: NoPermission}, err
+	}
+	return combined, nil
+}
+
+func v1CheckItem(ir rel.Interface) *v1.CheckBulkPermissionsRequestItem {
+	r := ir.Relationship()
+	return &v1.CheckBulkPermissionsRequestItem{
+		Resource: &v1.ObjectReference{
+			ObjectType: r.ResourceType,
+			ObjectId:   r.ResourceID,
+		},
+		Permission: r.ResourceRelation,
+		Subject: v1SubjectRef(rel.Object{
+			Typ:      r.SubjectType,
+			ID:       r.SubjectID,
+			Relation: r.SubjectRelation,
+		}),
+		Context: r.MustV1ProtoCaveat().GetContext(),
+	}
+}
+
+func lacksPermission(r CheckResult) bool {
+	return r.Err == nil && r.Permissionship == NoPermission
+}
+
+func checkResultFromV1(p v1.CheckPermissionResponse_Permissionship, info *v1.PartialCaveatInfo, checkedAt string) CheckResult {
+	switch p {
+	case v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION:
+		return CheckResult{Permissionship: HasPermission, CheckedAt: checkedAt}
+	case v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION:
+		return CheckResult{
+			Permissionship:      ConditionalPermission,
+			MissingCaveatFields: info.GetMissingRequiredContext(),
+			CheckedAt:           checkedAt,
+		}
+	}
+	return CheckResult{Permissionship: NoPermission, CheckedAt: checkedAt}
+}
+
+// appendMissing appends the fields that are not already present.
+func appendMissing(fields, additional []string) []string {
+	for _, field := range additional {
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,293 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// checkServer answers bulk checks based on the ID of each subject:
+// "allowed", "denied", "conditional", or "invalid" for a per-item error.
+//
+// Conditional results are missing a caveat field named after the resource ID
+// so that callers can verify the alignment of the results.
+type checkServer struct {
+	v1.UnimplementedPermissionsServiceServer
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func (s *checkServer) CheckBulkPermissions(_ context.Context, req *v1.CheckBulkPermissionsRequest) (*v1.CheckBulkPermissionsResponse, error) {
+	s.mu.Lock()
+	s.batches = append(s.batches, len(req.Items))
+	s.mu.Unlock()
+
+	resp := &v1.CheckBulkPermissionsResponse{CheckedAt: &v1.ZedToken{Token: "checked"}}
+	for _, item := range req.Items {
+		pair := &v1.CheckBulkPermissionsPair{Request: item}
+		responseItem := &v1.CheckBulkPermissionsResponseItem{}
+		switch item.Subject.Object.ObjectId {
+		case "allowed":
+			responseItem.Permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+		case "denied":
+			responseItem.Permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+		case "conditional":
+			responseItem.Permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+			responseItem.PartialCaveatInfo = &v1.PartialCaveatInfo{MissingRequiredContext: []string{item.Resource.ObjectId}}
+		default:
+			pair.Response = &v1.CheckBulkPermissionsPair_Error{
+				Error: status.New(codes.InvalidArgument, "invalid subject").Proto(),
+			}
+			resp.Pairs = append(resp.Pairs, pair)
+			continue
+		}
+		pair.Response = &v1.CheckBulkPermissionsPair_Item{Item: responseItem}
+		resp.Pairs = append(resp.Pairs, pair)
+	}
+	return resp, nil
+}
+
+func (s *checkServer) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.batches)
+}
+
+// checkRels returns a relationship for each of the provided subject IDs with
+// the index of the subject as the resource ID.
+func checkRels(subjects ...string) []rel.Interface {
+	rs := make([]rel.Interface, 0, len(subjects))
+	for i, subject := range subjects {
+		rs = append(rs, rel.MustFromTriple("document:"+strconv.Itoa(i), "view", "user:"+subject))
+	}
+	return rs
+}
+
+func TestCheckDetailed(t *testing.T) {
+	cases := []struct {
+		name     string
+		subject  string
+		expected CheckResult
+	}{
+		{"has permission", "allowed", CheckResult{Permissionship: HasPermission, CheckedAt: "checked"}},
+		{"no permission", "denied", CheckResult{Permissionship: NoPermission, CheckedAt: "checked"}},
+		{"conditional permission", "conditional", CheckResult{Permissionship: ConditionalPermission, MissingCaveatFields: []string{"0"}, CheckedAt: "checked"}},
+	}
+
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, &checkServer{}) })
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := c.CheckDetailed(context.Background(), consistency.MinLatency(), checkRels(tc.subject)...)
+			if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(results, []CheckResult{tc.expected}) {
+				t.Fatalf("unexpected results: %+v", results)
+			}
+
+			allowed, err := c.CheckOne(context.Background(), consistency.MinLatency(), checkRels(tc.subject)[0])
+			if err != nil {
+				t.Fatal(err)
+			} else if allowed != tc.expected.Allowed() {
+				t.Fatalf("expected CheckOne to return %t", tc.expected.Allowed())
+			}
+		})
+	}
+}
+
+func TestCheckAnyAllDetailed(t *testing.T) {
+	cases := []struct {
+		name        string
+		subjects    []string
+		expectedAny CheckResult
+		expectedAll CheckResult
+	}{
+		{
+			"all allowed",
+			[]string{"allowed", "allowed"},
+			CheckResult{Permissionship: HasPermission, CheckedAt: "checked"},
+			CheckResult{Permissionship: HasPermission, CheckedAt: "checked"},
+		},
+		{
+			"one denied",
+			[]string{"allowed", "denied"},
+			CheckResult{Permissionship: HasPermission, CheckedAt: "checked"},
+			CheckResult{Permissionship: NoPermission, CheckedAt: "checked"},
+		},
+		{
+			"conditional unions missing fields",
+			[]string{"conditional", "denied", "conditional"},
+			CheckResult{Permissionship: ConditionalPermission, MissingCaveatFields: []string{"0", "2"}, CheckedAt: "checked"},
+			CheckResult{Permissionship: NoPermission, CheckedAt: "checked"},
+		},
+		{
+			"conditional without denials",
+			[]string{"allowed", "conditional"},
+			CheckResult{Permissionship: HasPermission, CheckedAt: "checked"},
+			CheckResult{Permissionship: ConditionalPermission, MissingCaveatFields: []string{"1"}, CheckedAt: "checked"},
+		},
+	}
+
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, &checkServer{}) })
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := c.CheckAnyDetailed(context.Background(), consistency.MinLatency(), checkRels(tc.subjects...))
+			if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(result, tc.expectedAny) {
+				t.Fatalf("unexpected CheckAny result: %+v", result)
+			}
+
+			result, err = c.CheckAllDetailed(context.Background(), consistency.MinLatency(), checkRels(tc.subjects...))
+			if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(result, tc.expectedAll) {
+				t.Fatalf("unexpected CheckAll result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestCheckItemErrors(t *testing.T) {
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, &checkServer{}) })
+	ctx := context.Background()
+	rs := checkRels("allowed", "invalid", "denied")
+
+	results, err := c.CheckDetailed(ctx, consistency.MinLatency(), rs...)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != len(rs) {
+		t.Fatalf("expected %d results, got %d", len(rs), len(results))
+	} else if !results[0].Allowed() || results[0].Err != nil {
+		t.Fatalf("unexpected first result: %+v", results[0])
+	} else if status.Code(results[1].Err) != codes.InvalidArgument {
+		t.Fatalf("expected the status of the failed item, got %v", results[1].Err)
+	} else if results[2].Allowed() || results[2].Err != nil {
+		t.Fatalf("unexpected last result: %+v", results[2])
+	}
+
+	allowed, err := c.Check(ctx, consistency.MinLatency(), rs...)
+	var itemErr *CheckItemError
+	if !errors.As(err, &itemErr) {
+		t.Fatalf("expected CheckItemError, got %v", err)
+	} else if itemErr.Index != 1 || status.Code(itemErr) != codes.InvalidArgument {
+		t.Fatalf("unexpected item error: %v", itemErr)
+	} else if !reflect.DeepEqual(allowed, []bool{true, false, false}) {
+		t.Fatalf("unexpected results: %v", allowed)
+	}
+
+	cases := []struct {
+		name        string
+		check       func(context.Context, *consistency.Strategy, []rel.Interface) (bool, error)
+		subjects    []string
+		expected    bool
+		expectedErr bool
+	}{
+		{"any ignores errors when allowed", c.CheckAny, []string{"invalid", "allowed"}, true, false},
+		{"any reports errors when not allowed", c.CheckAny, []string{"invalid", "denied"}, false, true},
+		{"all ignores errors when denied", c.CheckAll, []string{"invalid", "denied"}, false, false},
+		{"all reports errors when not denied", c.CheckAll, []string{"invalid", "allowed"}, false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			allowed, err := tc.check(ctx, consistency.MinLatency(), checkRels(tc.subjects...))
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("unexpected error: %v", err)
+			} else if allowed != tc.expected {
+				t.Fatalf("expected %t", tc.expected)
+			}
+		})
+	}
+
+	// A caller that ignores the error must not be granted access.
+	result, err := c.CheckAllDetailed(ctx, consistency.MinLatency(), checkRels("allowed", "invalid", "conditional"))
+	if err == nil {
+		t.Fatal("expected an error")
+	} else if result.Permissionship != NoPermission || result.Allowed() {
+		t.Fatalf("expected NoPermission with the error, got %+v", result)
+	}
+}
+
+func TestCheckChunking(t *testing.T) {
+	srv := &checkServer{}
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) }, WithCheckChunking(3, 2))
+
+	subjects := make([]string, 10)
+	for i := range subjects {
+		subjects[i] = "conditional"
+	}
+	subjects[4], subjects[8] = "invalid", "allowed"
+
+	results, err := c.CheckDetailed(context.Background(), consistency.MinLatency(), checkRels(subjects...)...)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != len(subjects) {
+		t.Fatalf("expected %d results, got %d", len(subjects), len(results))
+	}
+	for i, result := range results {
+		switch i {
+		case 4:
+			if status.Code(result.Err) != codes.InvalidArgument {
+				t.Fatalf("expected item %d to fail, got %+v", i, result)
+			}
+		case 8:
+			if !result.Allowed() {
+				t.Fatalf("expected item %d to be allowed, got %+v", i, result)
+			}
+		default:
+			if !reflect.DeepEqual(result.MissingCaveatFields, []string{strconv.Itoa(i)}) {
+				t.Fatalf("result %d is out of order: %+v", i, result)
+			}
+		}
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.batches) != 4 {
+		t.Fatalf("expected 4 chunks, got %v", srv.batches)
+	}
+	for _, size := range srv.batches {
+		if size > 3 {
+			t.Fatalf("chunk exceeded the chunk size: %v", srv.batches)
+		}
+	}
+}
+
+func TestCheckChunkingStopsEarly(t *testing.T) {
+	cases := []struct {
+		name     string
+		check    func(*Client, context.Context, *consistency.Strategy, []rel.Interface) (bool, error)
+		subjects []string
+		expected bool
+	}{
+		{"any stops on allowed", (*Client).CheckAny, []string{"denied", "allowed", "denied", "denied", "denied", "denied"}, true},
+		{"all stops on denied", (*Client).CheckAll, []string{"allowed", "denied", "allowed", "allowed", "allowed", "allowed"}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := &checkServer{}
+			c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) }, WithCheckChunking(1, 1))
+
+			allowed, err := tc.check(c, context.Background(), consistency.MinLatency(), checkRels(tc.subjects...))
+			if err != nil {
+				t.Fatal(err)
+			} else if allowed != tc.expected {
+				t.Fatalf("expected %t", tc.expected)
+			} else if requests := srv.requests(); requests >= len(tc.subjects) {
+				t.Fatalf("expected remaining chunks to be skipped, got %d requests", requests)
+			}
+		})
+	}
+}
@@ -5,75 +5,130 @@ package client
 import (
 	"context"
 	"errors"
-	"fmt"
 	"io"
-	"strings"
-	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/authzed-go/v1"
 	"github.com/authzed/grpcutil"
-	"github.com/cenkalti/backoff/v4"
-	"golang.org/x/exp/slices"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/status"
-
-	_ "github.com/mostynb/go-grpc-compression/experimental/s2" // Register Snappy S2 compression
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/jzelinskie/gochugaru/consistency"
 	"github.com/jzelinskie/gochugaru/rel"
 )
 
-var defaultClientOpts = []grpc.DialOption{
-	grpc.WithDefaultCallOptions(grpc.UseCompressor("s2")),
-}
-
 // NewPlaintext creates a client that does not enforce TLS.
 //
 // This should be used only for testing (usually against localhost).
-func NewPlaintext(endpoint, presharedKey string) (*Client, error) {
-	return NewWithOpts(endpoint, append(
-		defaultClientOpts,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpcutil.WithInsecureBearerToken(presharedKey),
-	)...)
+func NewPlaintext(endpoint, presharedKey string, opts ...Option) (*Client, error) {
+	return New(endpoint, append([]Option{
+		withTransport(grpc.WithTransportCredentials(insecure.NewCredentials()), true),
+		withPresharedKey(presharedKey),
+	}, opts...)...)
 }
 
 // NewSystemTLS creates a client using TLS verified by the operating
 // system's certificate chain.
 //
 // This should be sufficient for production usage in the typical environments.
-func NewSystemTLS(endpoint, presharedKey string) (*Client, error) {
+func NewSystemTLS(endpoint, presharedKey string, opts ...Option) (*Client, error) {
 	withSystemCerts, err := grpcutil.WithSystemCerts(grpcutil.VerifyCA)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewWithOpts(endpoint, append(
-		defaultClientOpts,
-		withSystemCerts,
-		grpcutil.WithBearerToken("t_your_token_here_1234567deadbeef"),
-	)...)
+	return New(endpoint, append([]Option{
+		withTransport(withSystemCerts, false),
+		withPresharedKey(presharedKey),
+	}, opts...)...)
 }
 
 // NewWithOpts creates a client that allows for configuring gRPC options.
 //
-// This should only be used if the other methods don't suffice.
+// This is equivalent to New with WithDialOptions; use New to configure the
+// client's other options.
 //
-// I'd love to hear about what DialOptions you're using in the SpiceDB Discord
-// (https://discord.gg/spicedb) or the issue tracker for this library.
+// This should only be used if the other methods don't suffice.
 func NewWithOpts(endpoint string, opts ...grpc.DialOption) (*Client, error) {
-	client, err := authzed.NewClientWithExperimentalAPIs(endpoint, opts...)
+	return New(endpoint, WithDialOptions(opts...))
+}
+
+// New creates a client that allows for configuring every option.
+//
+// Unlike the other constructors, transport security must be provided using
+// WithDialOptions and credentials using WithCredentials.
+//
+// This should only be used if the other methods don't suffice.
+func New(endpoint string, opts ...Option) (*Client, error) {
+	o := newOptions(opts)
+
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	cp := &capabilities{}
+	conn, err := grpc.NewClient(endpoint, append(
+		o.grpcDialOpts(),
+		grpc.WithChainUnaryInterceptor(cp.unaryInterceptor),
+		grpc.WithChainStreamInterceptor(cp.streamInterceptor),
+	)...)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{client: client}, nil
+
+	c := &Client{
+		conn:         conn,
+		capabilities: cp,
+		client: &authzed.ClientWithExperimental{
+			Client: authzed.Client{
+				SchemaServiceClient:      v1.NewSchemaServiceClient(conn),
+				PermissionsServiceClient: v1.NewPermissionsServiceClient(conn),
+				WatchServiceClient:       v1.NewWatchServiceClient(conn),
+			},
+			ExperimentalServiceClient: v1.NewExperimentalServiceClient(conn),
+		},
+		health:           healthpb.NewHealthClient(conn),
+		checkChunkSize:   o.checkChunkSize,
+		checkConcurrency: o.checkConcurrency,
+		checkCache:       o.checkCache,
+		retryPolicy:      o.retryPolicy,
+	}
+	if o.deduplicate {
+		c.flights = &flightGroup{}
+	}
+	if o.batchWindow > 0 {
+		c.checkBatcher = newCheckBatcher(c, o.batchWindow, o.batchMaxSize)
+	}
+	return c, nil
 }
 
+// defaultEndpoint is used when an empty endpoint is provided, matching the
+// official client.
+const defaultEndpoint = "grpc.authzed.com:443"
+
 type Client struct {
-	client *authzed.ClientWithExperimental
+	conn         *grpc.ClientConn
+	client       *authzed.ClientWithExperimental
+	health       healthpb.HealthClient
+	capabilities *capabilities
+
+	checkChunkSize   int
+	checkConcurrency int
+	checkBatcher     *checkBatcher
+	checkCache       *CheckCache
+	flights          *flightGroup
+	retryPolicy      RetryPolicy
+}
+
+// CheckBatchingStats returns the metrics collected while batching checks.
+//
+// All of the values are zero if batching is not enabled.
+func (c *Client) CheckBatchingStats() BatchStats {
+	if c.checkBatcher == nil {
+		return BatchStats{}
+	}
+	return c.checkBatcher.snapshot()
 }
 
 // Write atomically performs a transaction on relationships.
@@ -85,179 +140,95 @@ func (c *Client) Write(ctx context.Context, txn *rel.Txn) (writtenAtRevision str
 	if err != nil {
 		return "", err
 	}
+	c.checkCache.wrote(resp.WrittenAt.GetToken())
 	return resp.WrittenAt.Token, nil
 }
 
 // CheckOne performs a permissions check for a single relationship.
+//
+// If batching is enabled, concurrent calls are combined into batched checks.
 func (c *Client) CheckOne(ctx context.Context, cs *consistency.Strategy, r rel.Interface) (bool, error) {
-	results, err := c.Check(ctx, cs, r)
+	result, err := c.CheckOneDetailed(ctx, cs, r)
 	if err != nil {
 		return false, err
 	}
-	return results[0], nil
+	return result.Allowed(), nil
 }
 
 // CheckAny returns true if any of the provided relationships have access.
+//
+// Failed items only cause an error if no other item has access.
 func (c *Client) CheckAny(ctx context.Context, cs *consistency.Strategy, rs []rel.Interface) (bool, error) {
-	results, err := c.Check(ctx, cs, rs...)
+	result, err := c.CheckAnyDetailed(ctx, cs, rs)
 	if err != nil {
 		return false, err
 	}
-
-	return slices.Contains(results, true), nil
+	return result.Allowed(), nil
 }
 
 // CheckAll returns true if all of the provided relationships have access.
+//
+// Failed items only cause an error if no other item lacks access.
 func (c *Client) CheckAll(ctx context.Context, cs *consistency.Strategy, rs []rel.Interface) (bool, error) {
-	results, err := c.Check(ctx, cs, rs...)
+	result, err := c.CheckAllDetailed(ctx, cs, rs)
 	if err != nil {
 		return false, err
 	}
-
-	for _, result := range results {
-		if !result {
-			return false, nil
-		}
-	}
-	return true, nil
-}
-
-// withBackoffRetriesAndTimeout is a utility to wrap an API call with retry
-// and backoff logic based on the error or gRPC status code.
-func withBackoffRetriesAndTimeout(ctx context.Context, fn func(context.Context) error) error {
-	backoffInterval := backoff.NewExponentialBackOff()
-	backoffInterval.InitialInterval = 50 * time.Millisecond
-	backoffInterval.MaxInterval = 2 * time.Second
-	backoffInterval.MaxElapsedTime = 0
-	backoffInterval.Reset()
-
-	maxRetries := 10
-	defaultTimeout := 30 * time.Second
-
-	for retryCount := 0; ; retryCount++ {
-		cancelCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
-		err := fn(cancelCtx)
-		cancel()
-
-		if isRetriable(err) && retryCount < maxRetries {
-			time.Sleep(backoffInterval.NextBackOff())
-			retryCount++
-			continue
-		}
-		break
-	}
-	return errors.New("max retries exceeded")
-}
-
-// isRetriable determines whether or not an error returned by the gRPC client
-// can be retried.
-func isRetriable(err error) bool {
-	switch {
-	case err == nil:
-		return false
-	case isGrpcCode(err, codes.Unavailable, codes.DeadlineExceeded):
-		return true
-	case errContains(err, "retryable error", "try restarting transaction"):
-		return true // SpiceDB < v1.30 need this to properly retry.
-	}
-	return errors.Is(err, context.DeadlineExceeded)
-}
-
-func isGrpcCode(err error, codes ...codes.Code) bool {
-	if err == nil {
-		return false
-	}
-
-	if s, ok := status.FromError(err); ok {
-		return slices.Contains(codes, s.Code())
-	}
-	return false
-}
-
-func errContains(err error, errStrs ...string) bool {
-	if err == nil {
-		return false
-	}
-
-	for _, errStr := range errStrs {
-		if strings.Contains(err.Error(), errStr) {
-			return true
-		}
-	}
-	return false
+	return result.Allowed(), nil
 }
 
 // Check performs a batched permissions check for the provided relationships.
+//
+// Conditional permissions are treated as not having permission; use
+// CheckDetailed to distinguish them or to get the revision at which each
+// relationship was checked.
+//
+// The results are always aligned with the provided relationships. If any
+// individual items failed, they are false and the returned error joins a
+// CheckItemError for each of them.
 func (c *Client) Check(ctx context.Context, cs *consistency.Strategy, rs ...rel.Interface) ([]bool, error) {
-	items := make([]*v1.BulkCheckPermissionRequestItem, 0, len(rs))
-	for _, ir := range rs {
-		r := ir.Relationship()
-		items = append(items, &v1.BulkCheckPermissionRequestItem{
-			Resource: &v1.ObjectReference{
-				ObjectType: r.ResourceType,
-				ObjectId:   r.ResourceID,
-			},
-			Permission: r.ResourceRelation,
-			Subject: &v1.SubjectReference{
-				Object: &v1.ObjectReference{
-					ObjectType: r.SubjectType,
-					ObjectId:   r.SubjectRelation,
-				},
-				OptionalRelation: r.SubjectRelation,
-			},
-			Context: r.MustV1ProtoCaveat().GetContext(),
-		})
-	}
-
-	var resp *v1.BulkCheckPermissionResponse
-	if err := withBackoffRetriesAndTimeout(ctx, func(cCtx context.Context) (cErr error) {
-		resp, cErr = c.client.BulkCheckPermission(cCtx, &v1.BulkCheckPermissionRequest{
-			Consistency: cs.V1Consistency,
-			Items:       items,
-		})
-		return cErr
-	}); err != nil {
+	detailed, err := c.CheckDetailed(ctx, cs, rs...)
+	if err != nil {
 		return nil, err
 	}
 
-	var results []bool
-	for _, pair := range resp.Pairs {
-		switch resp := pair.Response.(type) {
-		case *v1.BulkCheckPermissionPair_Item:
-			results = append(
-				results,
-				resp.Item.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
-			)
-		case *v1.BulkCheckPermissionPair_Error:
-			return results, errors.New(resp.Error.Message)
-		}
+	results := make([]bool, 0, len(detailed))
+	for _, result := range detailed {
+		results = append(results, result.Allowed())
 	}
-	return results, nil
+	return results, itemErrors(detailed)
 }
 
 // ForEachRelationship calls the provided function for each relationship
 // matching the provided filter.
-func (c *Client) ForEachRelationship(ctx context.Context, cs *consistency.Strategy, f *rel.Filter, fn rel.Func) error {
+//
+// The returned revision is the one at which the relationships were read and
+// can be used with consistency.Snapshot to perform follow-up reads. It is
+// empty if no relationships matched the filter.
+func (c *Client) ForEachRelationship(ctx context.Context, cs *consistency.Strategy, f *rel.Filter, fn rel.Func) (readAtRevision string, err error) {
 	stream, err := c.client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
 		Consistency:        cs.V1Consistency,
 		RelationshipFilter: f.V1Filter,
 		// TODO(jzelinskie): handle pagination for folks
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	for resp, err := stream.Recv(); err != io.EOF; resp, err = stream.Recv() {
 		if err != nil {
-			return err
+			return readAtRevision, err
 		}
 
+		if readAtRevision == "" {
+			readAtRevision = resp.ReadAt.GetToken()
+		}
 		if err := fn(rel.FromV1Proto(resp.Relationship)); err != nil {
-			return err
+			return readAtRevision, err
 		}
 	}
 
-	return nil
+	return readAtRevision, nil
 }
 
 // DeleteAtomic removes all of the relationships matching the provided filter
@@ -275,6 +246,7 @@ func (c *Client) DeleteAtomic(ctx context.Context, f *rel.PreconditionedFilter)
 	} else if resp.DeletionProgress != v1.DeleteRelationshipsResponse_DELETION_PROGRESS_COMPLETE {
 		return "", errors.New("delete disallowing partial deletion did not complete")
 	}
+	c.checkCache.wrote(resp.DeletedAt.GetToken())
 
 	return resp.DeletedAt.Token, nil
 }
@@ -284,7 +256,7 @@ func (c *Client) DeleteAtomic(ctx context.Context, f *rel.PreconditionedFilter)
 func (c *Client) Delete(ctx context.Context, f *rel.PreconditionedFilter) error {
 	for {
 		var resp *v1.DeleteRelationshipsResponse
-		if err := withBackoffRetriesAndTimeout(ctx, func(cCtx context.Context) (cErr error) {
+		if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
 			resp, cErr = c.client.DeleteRelationships(cCtx, &v1.DeleteRelationshipsRequest{
 				RelationshipFilter:            f.V1Filter,
 				OptionalPreconditions:         f.V1Preconds,
@@ -293,8 +265,12 @@ func (c *Client) Delete(ctx context.Context, f *rel.PreconditionedFilter) error
 			})
 			return cErr
 		}); err != nil {
+			c.checkCache.wrote("")
 			return err
-		} else if resp.DeletionProgress == v1.DeleteRelationshipsResponse_DELETION_PROGRESS_COMPLETE {
+		}
+
+		c.checkCache.wrote(resp.DeletedAt.GetToken())
+		if resp.DeletionProgress == v1.DeleteRelationshipsResponse_DELETION_PROGRESS_COMPLETE {
 			break
 		}
 	}
@@ -362,11 +338,17 @@ func (c *Client) ForEachUpdateFromRevision(ctx context.Context, objTypes []strin
 }
 
 // ReadSchema reads the current schema with full consistency.
+//
+// If deduplication is enabled, concurrent calls share a single read that
+// began after all of them were made.
 func (c *Client) ReadSchema(ctx context.Context) (schema, revision string, err error) {
-	resp, err := c.client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	result, err := c.flights.doFresh(ctx, "ReadSchema", func(ctx context.Context) (any, error) {
+		return c.client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	})
 	if err != nil {
 		return schema, revision, err
 	}
+	resp := result.(*v1.ReadSchemaResponse)
 	return resp.SchemaText, resp.ReadAt.Token, nil
 }
 
@@ -379,48 +361,7 @@ func (c *Client) WriteSchema(ctx context.Context, schema string) (revision strin
 	if err != nil {
 		return revision, err
 	}
+	// Schema changes never appear in the watch.
+	c.checkCache.wrote("")
 	return resp.WrittenAt.Token, nil
 }
-
-// ExportRelationships is similar to ReadRelationships, but cannot be filtered
-// and is optimized for performing full backups of SpiceDB.
-//
-// A proper backup should include relationships and schema, so this function
-// should be called with the same revision as said schema.
-func (c *Client) ExportRelationships(ctx context.Context, fn rel.Func, revision string) error {
-	relationshipStream, err := c.client.BulkExportRelationships(ctx, &v1.BulkExportRelationshipsRequest{
-		Consistency: &v1.Consistency{
-			Requirement: &v1.Consistency_AtExactSnapshot{
-				AtExactSnapshot: &v1.ZedToken{Token: revision},
-			},
-		},
-	})
-	if err != nil {
-		return err
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-			if err := ctx.Err(); err != nil {
-				return fmt.Errorf("aborted backup: %w", err)
-			}
-
-			relsResp, err := relationshipStream.Recv()
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					return nil
-				}
-				return fmt.Errorf("error receiving relationships: %w", err)
-			}
-
-			for _, r := range relsResp.Relationships {
-				if err := fn(rel.FromV1Proto(r)); err != nil {
-					return err
-				}
-			}
-		}
-	}
-}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// newTestClient starts a gRPC server with the services registered by the
+// provided function and returns a client connected to it.
+func newTestClient(t *testing.T, register func(*grpc.Server), opts ...Option) *Client {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	c, err := NewPlaintext(lis.Addr().String(), "", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+type readRelationshipsServer struct {
+	v1.UnimplementedPermissionsServiceServer
+	rels []*v1.Relationship
+}
+
+func (s readRelationshipsServer) ReadRelationships(_ *v1.ReadRelationshipsRequest, stream v1.PermissionsService_ReadRelationshipsServer) error {
+	for _, r := range s.rels {
+		if err := stream.Send(&v1.ReadRelationshipsResponse{
+			ReadAt:       &v1.ZedToken{Token: "read"},
+			Relationship: r,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestForEachRelationshipRevision(t *testing.T) {
+	c := newTestClient(t, func(srv *grpc.Server) {
+		v1.RegisterPermissionsServiceServer(srv, readRelationshipsServer{
+			rels: []*v1.Relationship{{
+				Resource: &v1.ObjectReference{ObjectType: "document", ObjectId: "example"},
+				Relation: "viewer",
+				Subject:  &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "jzelinskie"}},
+			}},
+		})
+	})
+
+	var count int
+	revision, err := c.ForEachRelationship(context.Background(), consistency.Full(), rel.NewFilter("document", "", ""), func(*rel.Relationship) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Fatalf("expected 1 relationship, got %d", count)
+	} else if revision != "read" {
+		t.Fatalf("unexpected revision: %q", revision)
+	}
+}
+
+func TestNewWithOptsDialOptions(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	checks := &checkServer{}
+	v1.RegisterPermissionsServiceServer(srv, checks)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	withInsecure := grpc.WithTransportCredentials(insecure.NewCredentials())
+	legacy, err := NewWithOpts(lis.Addr().String(), withInsecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer legacy.Close()
+
+	if _, err := legacy.Check(context.Background(), consistency.MinLatency(), checkRels("allowed", "denied")...); err != nil {
+		t.Fatal(err)
+	} else if requests := checks.requests(); requests != 1 {
+		t.Fatalf("expected a single check request, got %d", requests)
+	}
+
+	c, err := New(lis.Addr().String(), WithDialOptions(withInsecure), WithCheckChunking(1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Check(context.Background(), consistency.MinLatency(), checkRels("allowed", "denied")...); err != nil {
+		t.Fatal(err)
+	} else if requests := checks.requests(); requests != 3 {
+		t.Fatalf("expected the options to configure chunking, got %d requests", requests-1)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Credentials provide the bearer token (e.g. a SpiceDB preshared key) used to
+// authenticate each request.
+//
+// Implementations must be safe for concurrent use.
+type Credentials interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken returns Credentials that always use the provided token.
+func StaticToken(token string) Credentials { return staticToken(token) }
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) { return string(t), nil }
+
+// CredentialsFunc adapts a function into Credentials.
+//
+// The function is called for every request, so it should cache tokens if
+// computing them is expensive.
+type CredentialsFunc func(ctx context.Context) (string, error)
+
+func (f CredentialsFunc) Token(ctx context.Context) (string, error) { return f(ctx) }
+
+// fileTokenCheckInterval is how often a token file is checked for changes.
+const fileTokenCheckInterval = time.Second
+
+// FileToken returns Credentials that read the token from the provided file
+// and reload it whenever the file changes.
+//
+// This is useful for tokens mounted from Kubernetes Secrets that are rotated
+// without restarting the process.
+//
+// Once loaded successfully, failures to reload (e.g. a file that is briefly
+// missing while it is replaced) are ignored and the previously loaded token
+// remains in use until a reload succeeds.
+func FileToken(path string) (Credentials, error) {
+	ft := &fileToken{path: path}
+	if err := ft.reload(); err != nil {
+		return nil, err
+	}
+	return ft, nil
+}
+
+type fileToken struct {
+	path string
+
+	mu        sync.Mutex
+	token     string
+	modTime   time.Time
+	size      int64
+	lastCheck time.Time
+}
+
+func (ft *fileToken) Token(context.Context) (string, error) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	if time.Since(ft.lastCheck) >= fileTokenCheckInterval {
+		// The token was loaded when the credentials were created, so any
+		// error is a failed rotation that can be retried later.
+		_ = ft.reloadLocked()
+	}
+	return ft.token, nil
+}
+
+func (ft *fileToken) reload() error {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	return ft.reloadLocked()
+}
+
+func (ft *fileToken) reloadLocked() error {
+	ft.lastCheck = time.Now()
+
+	info, err := os.Stat(ft.path)
+	if err != nil {
+		return err
+	} else if info.ModTime().Equal(ft.modTime) && info.Size() == ft.size {
+		return nil
+	}
+
+	contents, err := os.ReadFile(ft.path)
+	if err != nil {
+		return err
+	}
+
+	token := string(bytes.TrimSpace(contents))
+	if token == "" {
+		return fmt.Errorf("token file %q is empty", ft.path)
+	}
+
+	ft.token = token
+	ft.modTime, ft.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// perRPCCredentials adapts Credentials to gRPC.
+type perRPCCredentials struct {
+	creds  Credentials
+	secure bool
+}
+
+var _ credentials.PerRPCCredentials = perRPCCredentials{}
+
+func (c perRPCCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.creds.Token(ctx)
+	if err != nil {
+		return nil, err
+	} else if token == "" {
+		return nil, nil
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (c perRPCCredentials) RequireTransportSecurity() bool { return c.secure }
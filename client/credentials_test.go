@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if _, err := FileToken(path); err == nil {
+		t.Fatal("expected an error for a missing token file")
+	}
+
+	writeToken := func(token string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		} else if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	writeToken("first", now.Add(-time.Hour))
+	creds, err := FileToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := creds.(*fileToken)
+
+	cases := []struct {
+		name     string
+		update   func()
+		expected string
+	}{
+		{"initial token", func() {}, "first"},
+		{"rotated token", func() { writeToken("second", now) }, "second"},
+		{"missing file keeps last token", func() {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+		}, "second"},
+		{"recovers after failed reload", func() { writeToken("third", now.Add(time.Hour)) }, "third"},
+		{"empty file keeps last token", func() { writeToken("", now.Add(2*time.Hour)) }, "third"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.update()
+
+			ft.mu.Lock()
+			ft.lastCheck = time.Time{}
+			ft.mu.Unlock()
+
+			if token, err := creds.Token(context.Background()); err != nil {
+				t.Fatal(err)
+			} else if token != c.expected {
+				t.Fatalf("expected %q, got %q", c.expected, token)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// ErrMissingEndpoint is returned when no endpoint could be found to connect
+// to.
+var ErrMissingEndpoint = errors.New("missing SpiceDB endpoint")
+
+// ErrZedContextNotFound is returned when a zed context could not be found in
+// zed's configuration directory.
+var ErrZedContextNotFound = errors.New("zed context not found")
+
+// NewFromEnv creates a client configured by the following environment
+// variables:
+//
+//   - SPICEDB_ENDPOINT: the address of SpiceDB (required)
+//   - SPICEDB_TOKEN: the preshared key used to authenticate
+//   - SPICEDB_INSECURE: if true, TLS is not used
+//   - SPICEDB_CA_CERT_PATH: a PEM-encoded certificate authority used to verify
+//     TLS instead of the operating system's
+//
+// This is useful for deployments that inject connection details into the
+// environment.
+func NewFromEnv(opts ...Option) (*Client, error) {
+	return newFromConnectionDetails(connectionDetails{
+		endpoint:   os.Getenv("SPICEDB_ENDPOINT"),
+		token:      os.Getenv("SPICEDB_TOKEN"),
+		insecure:   os.Getenv("SPICEDB_INSECURE"),
+		caCertPath: os.Getenv("SPICEDB_CA_CERT_PATH"),
+	}, opts...)
+}
+
+// NewFromZedContext creates a client using a context configured with the zed
+// CLI (i.e. `zed context set`).
+//
+// An empty name uses the current context. Just like zed, the ZED_ENDPOINT,
+// ZED_TOKEN, ZED_INSECURE, ZED_NO_VERIFY_CA, and ZED_CERTIFICATE_PATH
+// environment variables take precedence over the stored context.
+//
+// zed stores tokens in the operating system's keychain, which cannot be read
+// by this library. Instead, tokens are read from a secrets.json file in zed's
+// configuration directory (see LoadZedContext) and can always be provided by
+// the environment. If ZED_ENDPOINT is set, the context does not need to
+// exist.
+func NewFromZedContext(name string, opts ...Option) (*Client, error) {
+	zc, err := LoadZedContext(name)
+	if errors.Is(err, ErrZedContextNotFound) && os.Getenv("ZED_ENDPOINT") != "" {
+		zc, err = ZedContext{Name: name}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	details := connectionDetails{
+		endpoint:   zc.Endpoint,
+		token:      zc.APIToken,
+		caCertPath: os.Getenv("ZED_CERTIFICATE_PATH"),
+	}
+	if zc.Insecure != nil {
+		details.insecure = strconv.FormatBool(*zc.Insecure)
+	}
+	if endpoint := os.Getenv("ZED_ENDPOINT"); endpoint != "" {
+		details.endpoint = endpoint
+	}
+	if token := os.Getenv("ZED_TOKEN"); token != "" {
+		details.token = token
+	}
+	if insecure := os.Getenv("ZED_INSECURE"); insecure != "" {
+		details.insecure = insecure
+	}
+	if noVerifyCA := os.Getenv("ZED_NO_VERIFY_CA"); noVerifyCA != "" {
+		v, err := strconv.ParseBool(noVerifyCA)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for ZED_NO_VERIFY_CA: %w", err)
+		}
+		zc.NoVerifyCA = &v
+	}
+
+	if details.endpoint == "" {
+		return nil, ErrMissingEndpoint
+	}
+
+	insecure, _ := strconv.ParseBool(details.insecure)
+	switch {
+	case insecure:
+		return NewPlaintext(details.endpoint, details.token, opts...)
+	case zc.NoVerifyCA != nil && *zc.NoVerifyCA:
+		return New(details.endpoint, append([]Option{
+			withTransport(grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				MinVersion:         tls.VersionTLS12,
+				InsecureSkipVerify: true, // Explicitly requested by the zed context.
+			})), false),
+			withPresharedKey(details.token),
+		}, opts...)...)
+	case details.caCertPath == "" && len(zc.CACert) > 0:
+		return NewCustomCA(details.endpoint, details.token, zc.CACert, opts...)
+	}
+	return newFromConnectionDetails(details, opts...)
+}
+
+// ZedContext is a named set of connection details stored by the zed CLI.
+type ZedContext struct {
+	Name       string `json:"name"`
+	Endpoint   string `json:"endpoint"`
+	APIToken   string `json:"token"`
+	Insecure   *bool  `json:"insecure,omitempty"`
+	NoVerifyCA *bool  `json:"no_verify_ca,omitempty"`
+	CACert     []byte `json:"ca_cert,omitempty"`
+}
+
+// zedConfig is the format of the config.json file in zed's configuration
+// directory.
+type zedConfig struct {
+	CurrentToken string `json:"current_token"`
+}
+
+// zedSecrets is the format of the token storage in zed's configuration
+// directory.
+type zedSecrets struct {
+	Tokens []ZedContext `json:"tokens"`
+}
+
+// ZedConfigDir returns the directory where the zed CLI stores its
+// configuration.
+func ZedConfigDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".zed"), nil
+}
+
+// LoadZedContext reads the named context from zed's configuration directory.
+//
+// An empty name loads the current context from zed's config.json. The
+// context's details are read from a secrets.json file containing
+// `{"tokens": [...]}` with each token using the fields of ZedContext. If the
+// context is not found, ErrZedContextNotFound is returned.
+func LoadZedContext(name string) (ZedContext, error) {
+	dir, err := ZedConfigDir()
+	if err != nil {
+		return ZedContext{}, err
+	}
+
+	if name == "" {
+		var config zedConfig
+		if err := readJSONFile(filepath.Join(dir, "config.json"), &config); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return ZedContext{}, err
+		}
+		name = config.CurrentToken
+		if name == "" {
+			return ZedContext{}, fmt.Errorf("%w: no current context is set", ErrZedContextNotFound)
+		}
+	}
+
+	var secrets zedSecrets
+	if err := readJSONFile(filepath.Join(dir, "secrets.json"), &secrets); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return ZedContext{}, err
+	}
+	for _, zc := range secrets.Tokens {
+		if zc.Name == name {
+			return zc, nil
+		}
+	}
+	return ZedContext{}, fmt.Errorf("%w: %q", ErrZedContextNotFound, name)
+}
+
+func readJSONFile(path string, v any) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(contents, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// connectionDetails are the unparsed values used to pick a constructor.
+type connectionDetails struct {
+	endpoint   string
+	token      string
+	insecure   string
+	caCertPath string
+}
+
+func newFromConnectionDetails(d connectionDetails, opts ...Option) (*Client, error) {
+	if d.endpoint == "" {
+		return nil, ErrMissingEndpoint
+	}
+
+	if d.insecure != "" {
+		insecure, err := strconv.ParseBool(d.insecure)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for insecure: %w", err)
+		} else if insecure {
+			return NewPlaintext(d.endpoint, d.token, opts...)
+		}
+	}
+
+	if d.caCertPath != "" {
+		caPEM, err := os.ReadFile(d.caCertPath)
+		if err != nil {
+			return nil, err
+		}
+		return NewCustomCA(d.endpoint, d.token, caPEM, opts...)
+	}
+
+	return NewSystemTLS(d.endpoint, d.token, opts...)
+}
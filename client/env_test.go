@@ -0,0 +1,68 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFromEnvMissingEndpoint(t *testing.T) {
+	t.Setenv("SPICEDB_ENDPOINT", "")
+	if _, err := NewFromEnv(); !errors.Is(err, ErrMissingEndpoint) {
+		t.Fatalf("expected ErrMissingEndpoint, got %v", err)
+	}
+}
+
+func TestLoadZedContext(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	dir := filepath.Join(home, ".zed")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "config.json"), `{"current_token": "dev"}`)
+	writeFile(t, filepath.Join(dir, "secrets.json"), `{"tokens": [
+		{"name": "prod", "endpoint": "spicedb.prod:443", "token": "prodkey"},
+		{"name": "dev", "endpoint": "localhost:50051", "token": "devkey", "insecure": true}
+	]}`)
+
+	zc, err := LoadZedContext("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zc.Name != "dev" || zc.Endpoint != "localhost:50051" || zc.Insecure == nil || !*zc.Insecure {
+		t.Fatalf("unexpected current context: %+v", zc)
+	}
+
+	zc, err = LoadZedContext("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zc.APIToken != "prodkey" {
+		t.Fatalf("unexpected named context: %+v", zc)
+	}
+
+	if _, err := LoadZedContext("staging"); !errors.Is(err, ErrZedContextNotFound) {
+		t.Fatalf("expected ErrZedContextNotFound, got %v", err)
+	}
+	if _, err := NewFromZedContext("staging"); !errors.Is(err, ErrZedContextNotFound) {
+		t.Fatalf("expected ErrZedContextNotFound, got %v", err)
+	}
+
+	t.Setenv("ZED_ENDPOINT", "localhost:50051")
+	t.Setenv("ZED_INSECURE", "true")
+	c, err := NewFromZedContext("staging")
+	if err != nil {
+		t.Fatalf("expected the environment to replace the missing context: %v", err)
+	}
+	c.Close()
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,309 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// ExpandOperation is the set operation used to combine the children of a
+// node in an expanded permission tree.
+type ExpandOperation int
+
+const (
+	// ExpandLeaf means the node has no children and directly contains
+	// subjects.
+	ExpandLeaf ExpandOperation = iota
+
+	// ExpandUnion contains the subjects of any of the children.
+	ExpandUnion
+
+	// ExpandIntersection contains the subjects found in all of the children.
+	ExpandIntersection
+
+	// ExpandExclusion contains the subjects of the first child that are not
+	// found in any of the other children.
+	ExpandExclusion
+)
+
+func (op ExpandOperation) String() string {
+	switch op {
+	case ExpandLeaf:
+		return "leaf"
+	case ExpandUnion:
+		return "union"
+	case ExpandIntersection:
+		return "intersection"
+	case ExpandExclusion:
+		return "exclusion"
+	}
+	return "unknown"
+}
+
+// ExpandNode is a node in the tree returned by Expand.
+type ExpandNode struct {
+	// Expanded is the object and relation (or permission) that this node
+	// represents.
+	Expanded rel.Object
+
+	Operation ExpandOperation
+
+	// Children are the operands of the operation and are always empty for
+	// leaves.
+	Children []*ExpandNode
+
+	// Subjects are the subjects directly found in a leaf and are always empty
+	// for other operations.
+	//
+	// Subjects with a relation (e.g. `group:eng#member`) are not expanded
+	// further.
+	Subjects []rel.Object
+}
+
+// Walk calls the provided function for the node and each of its descendants
+// in depth-first order, stopping at the first error.
+func (n *ExpandNode) Walk(fn func(n *ExpandNode, depth int) error) error {
+	return n.walk(fn, 0)
+}
+
+func (n *ExpandNode) walk(fn func(n *ExpandNode, depth int) error, depth int) error {
+	if err := fn(n, depth); err != nil {
+		return err
+	}
+	for _, child := range n.Children {
+		if err := child.walk(fn, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlattenSubjects evaluates the operations of the tree and returns the
+// resulting subjects in the order they were first found.
+//
+// Subjects with a relation and wildcards are treated as opaque members of the
+// set: they are not expanded and only match identical subjects.
+func (n *ExpandNode) FlattenSubjects() []rel.Object {
+	return n.flatten().objs
+}
+
+// subjectSet is an insertion-ordered set of subjects.
+type subjectSet struct {
+	objs []rel.Object
+	seen map[rel.Object]struct{}
+}
+
+func (s *subjectSet) add(o rel.Object) {
+	if s.seen == nil {
+		s.seen = make(map[rel.Object]struct{})
+	}
+	if _, ok := s.seen[o]; !ok {
+		s.seen[o] = struct{}{}
+		s.objs = append(s.objs, o)
+	}
+}
+
+func (s *subjectSet) has(o rel.Object) bool {
+	_, ok := s.seen[o]
+	return ok
+}
+
+func (n *ExpandNode) flatten() *subjectSet {
+	result := &subjectSet{}
+	switch n.Operation {
+	case ExpandLeaf:
+		for _, subject := range n.Subjects {
+			result.add(subject)
+		}
+	case ExpandUnion:
+		for _, child := range n.Children {
+			for _, subject := range child.flatten().objs {
+				result.add(subject)
+			}
+		}
+	case ExpandIntersection, ExpandExclusion:
+		if len(n.Children) == 0 {
+			return result
+		}
+
+		others := make([]*subjectSet, 0, len(n.Children)-1)
+		for _, child := range n.Children[1:] {
+			others = append(others, child.flatten())
+		}
+
+		for _, subject := range n.Children[0].flatten().objs {
+			var foundIn int
+			for _, other := range others {
+				if other.has(subject) {
+					foundIn++
+				}
+			}
+
+			if (n.Operation == ExpandIntersection && foundIn == len(others)) ||
+				(n.Operation == ExpandExclusion && foundIn == 0) {
+				result.add(subject)
+			}
+		}
+	}
+	return result
+}
+
+// WriteTo writes the tree as ASCII art with one node or subject per line.
+func (n *ExpandNode) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	write := func(format string, args ...any) error {
+		c, err := fmt.Fprintf(w, format, args...)
+		written += int64(c)
+		return err
+	}
+
+	var writeNode func(n *ExpandNode, prefix, childPrefix string) error
+	writeNode = func(n *ExpandNode, prefix, childPrefix string) error {
+		label := objectString(n.Expanded)
+		if n.Operation != ExpandLeaf {
+			label += " (" + n.Operation.String() + ")"
+		}
+		if err := write("%s%s\n", prefix, label); err != nil {
+			return err
+		}
+
+		lines := len(n.Children) + len(n.Subjects)
+		for i, child := range n.Children {
+			branch, indent := asciiBranch(i == lines-1)
+			if err := writeNode(child, childPrefix+branch, childPrefix+indent); err != nil {
+				return err
+			}
+		}
+		for i, subject := range n.Subjects {
+			branch, _ := asciiBranch(len(n.Children)+i == lines-1)
+			if err := write("%s%s%s\n", childPrefix, branch, objectString(subject)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := writeNode(n, "", "")
+	return written, err
+}
+
+func asciiBranch(last bool) (branch, indent string) {
+	if last {
+		return "`-- ", "    "
+	}
+	return "|-- ", "|   "
+}
+
+// String returns the tree rendered as ASCII art.
+func (n *ExpandNode) String() string {
+	var b strings.Builder
+	_, _ = n.WriteTo(&b)
+	return b.String()
+}
+
+// WriteDOT writes the tree as a Graphviz DOT digraph.
+//
+// Each subject is rendered as a single node, even if it is found in multiple
+// leaves.
+func (n *ExpandNode) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph expand {\n")
+
+	nodeIDs := make(map[*ExpandNode]string)
+	subjectIDs := make(map[rel.Object]string)
+	_ = n.Walk(func(node *ExpandNode, _ int) error {
+		id := fmt.Sprintf("n%d", len(nodeIDs))
+		nodeIDs[node] = id
+
+		label := objectString(node.Expanded)
+		if node.Operation != ExpandLeaf {
+			label += "\n" + node.Operation.String()
+		}
+		fmt.Fprintf(&b, "  %s [label=%q];\n", id, label)
+
+		for _, subject := range node.Subjects {
+			subjectID, ok := subjectIDs[subject]
+			if !ok {
+				subjectID = fmt.Sprintf("s%d", len(subjectIDs))
+				subjectIDs[subject] = subjectID
+				fmt.Fprintf(&b, "  %s [label=%q, shape=box];\n", subjectID, objectString(subject))
+			}
+			fmt.Fprintf(&b, "  %s -> %s;\n", id, subjectID)
+		}
+		return nil
+	})
+	_ = n.Walk(func(node *ExpandNode, _ int) error {
+		for _, child := range node.Children {
+			fmt.Fprintf(&b, "  %s -> %s;\n", nodeIDs[node], nodeIDs[child])
+		}
+		return nil
+	})
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Expand returns the tree of relations and subjects that are used to compute
+// the provided permission (or relation) on the resource.
+//
+// The tree is only expanded a single level deep: subjects with a relation
+// must be expanded separately.
+func (c *Client) Expand(ctx context.Context, cs *consistency.Strategy, resource rel.Objecter, permission string) (tree *ExpandNode, expandedAtRevision string, err error) {
+	r := resource.Object()
+
+	var resp *v1.ExpandPermissionTreeResponse
+	if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
+		resp, cErr = c.client.ExpandPermissionTree(cCtx, &v1.ExpandPermissionTreeRequest{
+			Consistency: cs.V1Consistency,
+			Resource: &v1.ObjectReference{
+				ObjectType: r.Typ,
+				ObjectId:   r.ID,
+			},
+			Permission: permission,
+		})
+		return cErr
+	}); err != nil {
+		return nil, "", err
+	}
+
+	return expandNodeFromV1(resp.TreeRoot), resp.ExpandedAt.GetToken(), nil
+}
+
+func expandNodeFromV1(t *v1.PermissionRelationshipTree) *ExpandNode {
+	node := &ExpandNode{Expanded: rel.Object{
+		Typ:      t.GetExpandedObject().GetObjectType(),
+		ID:       t.GetExpandedObject().GetObjectId(),
+		Relation: t.GetExpandedRelation(),
+	}}
+
+	switch tree := t.GetTreeType().(type) {
+	case *v1.PermissionRelationshipTree_Intermediate:
+		switch tree.Intermediate.GetOperation() {
+		case v1.AlgebraicSubjectSet_OPERATION_UNION:
+			node.Operation = ExpandUnion
+		case v1.AlgebraicSubjectSet_OPERATION_INTERSECTION:
+			node.Operation = ExpandIntersection
+		case v1.AlgebraicSubjectSet_OPERATION_EXCLUSION:
+			node.Operation = ExpandExclusion
+		}
+		for _, child := range tree.Intermediate.GetChildren() {
+			node.Children = append(node.Children, expandNodeFromV1(child))
+		}
+	case *v1.PermissionRelationshipTree_Leaf:
+		for _, subject := range tree.Leaf.GetSubjects() {
+			node.Subjects = append(node.Subjects, rel.Object{
+				Typ:      subject.GetObject().GetObjectType(),
+				ID:       subject.GetObject().GetObjectId(),
+				Relation: subject.GetOptionalRelation(),
+			})
+		}
+	}
+	return node
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+type expandPermissionsServer struct {
+	v1.UnimplementedPermissionsServiceServer
+}
+
+func leaf(resource *v1.ObjectReference, relation string, subjects ...string) *v1.PermissionRelationshipTree {
+	set := &v1.DirectSubjectSet{}
+	for _, subject := range subjects {
+		typ, id, _ := strings.Cut(subject, ":")
+		set.Subjects = append(set.Subjects, &v1.SubjectReference{
+			Object: &v1.ObjectReference{ObjectType: typ, ObjectId: id},
+		})
+	}
+	return &v1.PermissionRelationshipTree{
+		TreeType:         &v1.PermissionRelationshipTree_Leaf{Leaf: set},
+		ExpandedObject:   resource,
+		ExpandedRelation: relation,
+	}
+}
+
+func (expandPermissionsServer) ExpandPermissionTree(_ context.Context, req *v1.ExpandPermissionTreeRequest) (*v1.ExpandPermissionTreeResponse, error) {
+	// view = (viewer + editor) - banned
+	return &v1.ExpandPermissionTreeResponse{
+		ExpandedAt: &v1.ZedToken{Token: "expanded"},
+		TreeRoot: &v1.PermissionRelationshipTree{
+			TreeType: &v1.PermissionRelationshipTree_Intermediate{Intermediate: &v1.AlgebraicSubjectSet{
+				Operation: v1.AlgebraicSubjectSet_OPERATION_EXCLUSION,
+				Children: []*v1.PermissionRelationshipTree{
+					{
+						TreeType: &v1.PermissionRelationshipTree_Intermediate{Intermediate: &v1.AlgebraicSubjectSet{
+							Operation: v1.AlgebraicSubjectSet_OPERATION_UNION,
+							Children: []*v1.PermissionRelationshipTree{
+								leaf(req.Resource, "viewer", "user:alice", "user:bob"),
+								leaf(req.Resource, "editor", "user:alice", "user:carol"),
+							},
+						}},
+						ExpandedObject:   req.Resource,
+						ExpandedRelation: req.Permission,
+					},
+					leaf(req.Resource, "banned", "user:bob"),
+				},
+			}},
+			ExpandedObject:   req.Resource,
+			ExpandedRelation: req.Permission,
+		},
+	}, nil
+}
+
+func TestExpand(t *testing.T) {
+	c := newTestClient(t, func(srv *grpc.Server) {
+		v1.RegisterPermissionsServiceServer(srv, expandPermissionsServer{})
+	})
+
+	tree, revision, err := c.Expand(context.Background(), consistency.Full(), rel.Object{Typ: "document", ID: "example"}, "view")
+	if err != nil {
+		t.Fatal(err)
+	} else if revision != "expanded" {
+		t.Fatalf("unexpected revision: %q", revision)
+	}
+
+	expectedSubjects := []rel.Object{{Typ: "user", ID: "alice"}, {Typ: "user", ID: "carol"}}
+	if subjects := tree.FlattenSubjects(); !reflect.DeepEqual(subjects, expectedSubjects) {
+		t.Fatalf("unexpected subjects: %v", subjects)
+	}
+
+	expectedASCII := "document:example#view (exclusion)\n" +
+		"|-- document:example#view (union)\n" +
+		"|   |-- document:example#viewer\n" +
+		"|   |   |-- user:alice\n" +
+		"|   |   `-- user:bob\n" +
+		"|   `-- document:example#editor\n" +
+		"|       |-- user:alice\n" +
+		"|       `-- user:carol\n" +
+		"`-- document:example#banned\n" +
+		"    `-- user:bob\n"
+	if got := tree.String(); got != expectedASCII {
+		t.Fatalf("unexpected ASCII:\n%s\nexpected:\n%s", got, expectedASCII)
+	}
+
+	var dot strings.Builder
+	if err := tree.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`n0 [label="document:example#view\nexclusion"];`,
+		`s0 [label="user:alice", shape=box];`,
+		`n3 -> s0;`,
+		`n0 -> n1;`,
+	} {
+		if !strings.Contains(dot.String(), line) {
+			t.Fatalf("expected DOT to contain %q:\n%s", line, dot.String())
+		}
+	}
+}
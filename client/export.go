@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// ErrCursorRevisionMismatch is returned when resuming an export from a cursor
+// that belongs to an export of a different revision.
+var ErrCursorRevisionMismatch = errors.New("export cursor does not match the requested revision")
+
+// ExportCursor is a position within an export that can be persisted in order
+// to resume the export later.
+type ExportCursor struct {
+	// Revision is the revision at which the export is pinned.
+	Revision string
+
+	// Token is the opaque position returned by SpiceDB after the last batch
+	// of exported relationships.
+	Token string
+}
+
+// ExportOption configures a call to ExportRelationships.
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	cursor     ExportCursor
+	checkpoint func(cursor ExportCursor) error
+}
+
+// WithExportCursor resumes an export after the position of the provided
+// cursor.
+//
+// The export is pinned to the revision of the cursor.
+func WithExportCursor(cursor ExportCursor) ExportOption {
+	return func(o *exportOptions) { o.cursor = cursor }
+}
+
+// WithExportCheckpoint configures a function that is called with the cursor
+// after each batch of relationships has been passed to the export's
+// function.
+//
+// Persisting the cursor allows an interrupted export to be resumed with
+// WithExportCursor. Returning an error aborts the export.
+func WithExportCheckpoint(fn func(cursor ExportCursor) error) ExportOption {
+	return func(o *exportOptions) { o.checkpoint = fn }
+}
+
+// ExportRelationships is similar to ReadRelationships, but cannot be filtered
+// and is optimized for performing full backups of SpiceDB.
+//
+// A proper backup should include relationships and schema, so this function
+// should be called with the same revision as said schema. If the provided
+// revision is empty, the revision of the resumed cursor or the current schema
+// is used.
+//
+// The export is pinned to a single revision, which is returned. If the
+// stream fails with a retriable error, it is transparently resumed from the
+// last batch of relationships received. The retry policy's AttemptTimeout
+// bounds each stream, which is also resumed once it expires as long as it
+// received relationships. Streams that received relationships do not count
+// towards the retry policy's attempts.
+func (c *Client) ExportRelationships(ctx context.Context, fn rel.Func, revision string, opts ...ExportOption) (exportedAtRevision string, err error) {
+	o := &exportOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cursor := o.cursor
+	switch {
+	case cursor.Revision != "" && revision != "" && cursor.Revision != revision:
+		return "", ErrCursorRevisionMismatch
+	case cursor.Revision == "" && revision != "":
+		cursor.Revision = revision
+	case cursor.Revision == "":
+		if _, cursor.Revision, err = c.ReadSchema(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	// Exports can stream for far longer than an attempt is allowed to take,
+	// so any attempt that made progress is resumed.
+	err = c.retryPolicy.doResumable(ctx, func(cCtx context.Context) (bool, error) {
+		attemptStart := cursor
+		err := c.exportFromCursor(cCtx, &cursor, fn, o.checkpoint)
+		return cursor != attemptStart, err
+	})
+
+	var callbackErr callbackError
+	switch {
+	case err == nil:
+		return cursor.Revision, nil
+	case errors.As(err, &callbackErr):
+		return cursor.Revision, callbackErr.err
+	case ctx.Err() != nil:
+		return cursor.Revision, fmt.Errorf("aborted backup: %w", ctx.Err())
+	}
+	return cursor.Revision, fmt.Errorf("error receiving relationships: %w", c.capabilities.unsupportedErr(err, APIExportBulkRelationships))
+}
+
+// exportFromCursor streams relationships after the cursor, advancing it
+// after each batch has been passed to the function.
+//
+// Errors returned by the function or checkpoint are wrapped in a
+// callbackError.
+func (c *Client) exportFromCursor(ctx context.Context, cursor *ExportCursor, fn rel.Func, checkpoint func(ExportCursor) error) error {
+	req := &v1.ExportBulkRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtExactSnapshot{
+				AtExactSnapshot: &v1.ZedToken{Token: cursor.Revision},
+			},
+		},
+	}
+	if cursor.Token != "" {
+		req.OptionalCursor = &v1.Cursor{Token: cursor.Token}
+	}
+
+	stream, err := c.client.ExportBulkRelationships(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		for _, r := range resp.Relationships {
+			if err := fn(rel.FromV1Proto(r)); err != nil {
+				return callbackError{err}
+			}
+		}
+
+		cursor.Token = resp.AfterResultCursor.GetToken()
+		if checkpoint != nil {
+			if err := checkpoint(*cursor); err != nil {
+				return callbackError{err}
+			}
+		}
+	}
+}
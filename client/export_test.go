@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// flakyExportServer exports batches of relationships and drops the stream
+// once after the first batch.
+type flakyExportServer struct {
+	v1.UnimplementedPermissionsServiceServer
+	batches int
+	delay   time.Duration
+
+	mu        sync.Mutex
+	dropped   bool
+	revisions []string
+}
+
+func (s *flakyExportServer) ExportBulkRelationships(req *v1.ExportBulkRelationshipsRequest, stream v1.PermissionsService_ExportBulkRelationshipsServer) error {
+	s.mu.Lock()
+	s.revisions = append(s.revisions, req.Consistency.GetAtExactSnapshot().GetToken())
+	s.mu.Unlock()
+
+	start := 0
+	if req.OptionalCursor != nil {
+		start, _ = strconv.Atoi(req.OptionalCursor.Token)
+	}
+
+	for i := start; i < s.batches; i++ {
+		s.mu.Lock()
+		drop := i == 1 && !s.dropped
+		s.dropped = s.dropped || drop
+		s.mu.Unlock()
+		if drop {
+			return status.Error(codes.Unavailable, "connection reset")
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-time.After(s.delay):
+		}
+
+		if err := stream.Send(&v1.ExportBulkRelationshipsResponse{
+			AfterResultCursor: &v1.Cursor{Token: strconv.Itoa(i + 1)},
+			Relationships: []*v1.Relationship{{
+				Resource: &v1.ObjectReference{ObjectType: "document", ObjectId: strconv.Itoa(i)},
+				Relation: "viewer",
+				Subject:  &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "jzelinskie"}},
+			}},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportRelationshipsResumes(t *testing.T) {
+	srv := &flakyExportServer{batches: 3}
+	c := newTestClient(t, func(s *grpc.Server) {
+		v1.RegisterPermissionsServiceServer(s, srv)
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+
+	var exported []string
+	var checkpoints []ExportCursor
+	revision, err := c.ExportRelationships(context.Background(), func(r *rel.Relationship) error {
+		exported = append(exported, r.ResourceID)
+		return nil
+	}, "snapshot", WithExportCheckpoint(func(cursor ExportCursor) error {
+		checkpoints = append(checkpoints, cursor)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	} else if revision != "snapshot" {
+		t.Fatalf("unexpected revision: %q", revision)
+	} else if fmt.Sprint(exported) != "[0 1 2]" {
+		t.Fatalf("unexpected relationships: %v", exported)
+	} else if fmt.Sprint(srv.revisions) != "[snapshot snapshot]" {
+		t.Fatalf("expected a reconnection pinned to the snapshot, got %v", srv.revisions)
+	} else if last := checkpoints[len(checkpoints)-1]; last != (ExportCursor{Revision: "snapshot", Token: "3"}) {
+		t.Fatalf("unexpected final checkpoint: %+v", last)
+	}
+
+	exported = nil
+	_, err = c.ExportRelationships(context.Background(), func(r *rel.Relationship) error {
+		exported = append(exported, r.ResourceID)
+		return nil
+	}, "", WithExportCursor(checkpoints[0]))
+	if err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprint(exported) != "[1 2]" {
+		t.Fatalf("unexpected relationships after resuming: %v", exported)
+	}
+
+	_, err = c.ExportRelationships(context.Background(), func(*rel.Relationship) error { return nil }, "other", WithExportCursor(checkpoints[0]))
+	if err != ErrCursorRevisionMismatch {
+		t.Fatalf("expected ErrCursorRevisionMismatch, got %v", err)
+	}
+}
+
+func TestExportRelationshipsAttemptTimeout(t *testing.T) {
+	srv := &flakyExportServer{batches: 6, delay: 20 * time.Millisecond}
+	c := newTestClient(t, func(s *grpc.Server) {
+		v1.RegisterPermissionsServiceServer(s, srv)
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, AttemptTimeout: 50 * time.Millisecond}))
+
+	var exported []string
+	_, err := c.ExportRelationships(context.Background(), func(r *rel.Relationship) error {
+		exported = append(exported, r.ResourceID)
+		return nil
+	}, "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprint(exported) != "[0 1 2 3 4 5]" {
+		t.Fatalf("unexpected relationships: %v", exported)
+	} else if len(srv.revisions) < 3 {
+		t.Fatalf("expected expired attempts to be resumed, got %d streams", len(srv.revisions))
+	}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/cenkalti/backoff/v4"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ErrNotServing is returned when SpiceDB reports that it is not serving
+// requests.
+var ErrNotServing = errors.New("SpiceDB is not serving")
+
+// Close closes the connection to SpiceDB.
+//
+// Any in-flight requests are cancelled and the client cannot be used
+// afterwards.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Ping checks whether SpiceDB is able to serve requests.
+//
+// The gRPC health service is used when available, otherwise a schema is read
+// as a cheap request that exercises the datastore.
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.health.Check(ctx, &healthpb.HealthCheckRequest{})
+	switch {
+	case isGrpcCode(err, codes.Unimplemented):
+		return c.pingWithReadSchema(ctx)
+	case err != nil:
+		return err
+	case resp.Status != healthpb.HealthCheckResponse_SERVING:
+		return fmt.Errorf("%w: %s", ErrNotServing, resp.Status)
+	}
+	return nil
+}
+
+func (c *Client) pingWithReadSchema(ctx context.Context) error {
+	_, err := c.client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	if isGrpcCode(err, codes.NotFound) {
+		return nil // No schema has been written yet.
+	}
+	return err
+}
+
+// WaitForReady blocks until Ping succeeds or the context is done.
+//
+// This is useful for readiness probes and for setting up integration tests.
+func (c *Client) WaitForReady(ctx context.Context) error {
+	backoffInterval := backoff.NewExponentialBackOff()
+	backoffInterval.InitialInterval = 50 * time.Millisecond
+	backoffInterval.MaxInterval = time.Second
+	backoffInterval.MaxElapsedTime = 0
+	backoffInterval.Reset()
+
+	for {
+		err := c.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(backoffInterval.NextBackOff())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w while waiting for SpiceDB: %w", ctx.Err(), err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestPing(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	healthServer := health.NewServer()
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, healthServer)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	c, err := NewPlaintext(lis.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.WaitForReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	if err := c.Ping(ctx); !errors.Is(err, ErrNotServing) {
+		t.Fatalf("expected ErrNotServing, got %v", err)
+	}
+}
+
+type countingSchemaServer struct {
+	v1.UnimplementedSchemaServiceServer
+	reads atomic.Int32
+}
+
+func (s *countingSchemaServer) ReadSchema(context.Context, *v1.ReadSchemaRequest) (*v1.ReadSchemaResponse, error) {
+	s.reads.Add(1)
+	return &v1.ReadSchemaResponse{ReadAt: &v1.ZedToken{Token: "rev"}}, nil
+}
+
+func TestNewSendsNoRequests(t *testing.T) {
+	srv := &countingSchemaServer{}
+	var intercepted atomic.Int32
+	// The interceptor rejects every request, like auth or rate limiting
+	// middleware might, which must not prevent the client from being built.
+	newTestClient(t, func(s *grpc.Server) { v1.RegisterSchemaServiceServer(s, srv) }, WithUnaryInterceptors(
+		func(context.Context, string, any, any, *grpc.ClientConn, grpc.UnaryInvoker, ...grpc.CallOption) error {
+			intercepted.Add(1)
+			return errors.New("rate limited")
+		},
+	))
+
+	if reads := srv.reads.Load(); reads != 0 {
+		t.Fatalf("expected no requests, got %d", reads)
+	} else if calls := intercepted.Load(); calls != 0 {
+		t.Fatalf("expected no intercepted calls, got %d", calls)
+	}
+}
@@ -0,0 +1,339 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+const defaultImportBatchSize = 1_000
+
+// RelationshipSource returns the next relationship to be imported or io.EOF
+// once there are no more relationships.
+type RelationshipSource func(ctx context.Context) (rel.Relationship, error)
+
+// SourceFromChannel returns a RelationshipSource that receives relationships
+// from the provided channel until it is closed.
+func SourceFromChannel(ch <-chan rel.Relationship) RelationshipSource {
+	return func(ctx context.Context) (rel.Relationship, error) {
+		select {
+		case <-ctx.Done():
+			return rel.Relationship{}, ctx.Err()
+		case r, ok := <-ch:
+			if !ok {
+				return rel.Relationship{}, io.EOF
+			}
+			return r, nil
+		}
+	}
+}
+
+// SourceFromSlice returns a RelationshipSource that iterates over the
+// provided relationships.
+func SourceFromSlice(rs []rel.Relationship) RelationshipSource {
+	return func(context.Context) (rel.Relationship, error) {
+		if len(rs) == 0 {
+			return rel.Relationship{}, io.EOF
+		}
+		r := rs[0]
+		rs = rs[1:]
+		return r, nil
+	}
+}
+
+// ImportOption configures a call to ImportRelationships.
+type ImportOption func(*importOptions)
+
+type importOptions struct {
+	batchSize int
+	progress  func(imported uint64)
+}
+
+// WithImportBatchSize configures the number of relationships sent in each
+// message or transaction.
+//
+// The default is 1,000.
+func WithImportBatchSize(size int) ImportOption {
+	return func(o *importOptions) { o.batchSize = max(size, 1) }
+}
+
+// WithImportProgress configures a function that is called with the total
+// number of relationships sent after every batch.
+func WithImportProgress(fn func(imported uint64)) ImportOption {
+	return func(o *importOptions) { o.progress = fn }
+}
+
+func (o *importOptions) reportProgress(imported uint64) {
+	if o.progress != nil {
+		o.progress(imported)
+	}
+}
+
+// ImportRelationships writes all of the relationships provided by the source
+// and returns the number of relationships imported.
+//
+// Relationships are streamed to SpiceDB's bulk import API in batches, which
+// is the fastest way to load relationships into an empty SpiceDB, but fails
+// if any of the relationships already exist. The import is atomic: no
+// relationships are written unless all of them are.
+//
+// If SpiceDB doesn't support bulk import, the experimental bulk import API of
+// older versions is used instead. If neither is supported, each batch is
+// instead written as a separate transaction that touches its relationships.
+// In this case, the import is not atomic, but is idempotent and can be safely
+// restarted.
+//
+// Support is detected from the response to the first batch, which can arrive
+// after more batches have been sent, so the first few batches are kept in
+// memory until they can no longer be needed by a fallback. If SpiceDB reports
+// that bulk import is unsupported any later, an UnsupportedAPIError is
+// returned without anything having been written and calling
+// ImportRelationships again uses a fallback.
+func (c *Client) ImportRelationships(ctx context.Context, source RelationshipSource, opts ...ImportOption) (imported uint64, err error) {
+	o := &importOptions{batchSize: defaultImportBatchSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	// The revisions of imports are unknown.
+	defer c.checkCache.wrote("")
+
+	for _, api := range []API{APIImportBulkRelationships, APIBulkImportRelationships} {
+		if !c.capabilities.supports(api) {
+			continue
+		}
+
+		imported, unsent, fallback, err := c.bulkImport(ctx, api, source, o)
+		if !fallback {
+			return imported, err
+		}
+
+		// Bulk import is unsupported, but some relationships were already
+		// read from the source, so they are written before the rest of it.
+		source = prependSource(unsent, source)
+	}
+	return c.touchImport(ctx, source, o)
+}
+
+// importStream is a stream of either of the bulk import APIs.
+type importStream interface {
+	send(rels []*v1.Relationship) error
+	closeAndRecv() (numLoaded uint64, err error)
+}
+
+type importBulkStream struct {
+	v1.PermissionsService_ImportBulkRelationshipsClient
+}
+
+func (s importBulkStream) send(rels []*v1.Relationship) error {
+	return s.Send(&v1.ImportBulkRelationshipsRequest{Relationships: rels})
+}
+
+func (s importBulkStream) closeAndRecv() (uint64, error) {
+	resp, err := s.CloseAndRecv()
+	return resp.GetNumLoaded(), err
+}
+
+type experimentalImportStream struct {
+	v1.ExperimentalService_BulkImportRelationshipsClient
+}
+
+func (s experimentalImportStream) send(rels []*v1.Relationship) error {
+	return s.Send(&v1.BulkImportRelationshipsRequest{Relationships: rels})
+}
+
+func (s experimentalImportStream) closeAndRecv() (uint64, error) {
+	resp, err := s.CloseAndRecv()
+	return resp.GetNumLoaded(), err
+}
+
+func (c *Client) openImportStream(ctx context.Context, api API) (importStream, error) {
+	if api == APIBulkImportRelationships {
+		stream, err := c.client.BulkImportRelationships(ctx)
+		return experimentalImportStream{stream}, err
+	}
+	stream, err := c.client.ImportBulkRelationships(ctx)
+	return importBulkStream{stream}, err
+}
+
+// importReplayBatches is the number of batches kept in memory so that they
+// can be imported by the fallback if SpiceDB reports that bulk import is
+// unsupported after they have been sent.
+const importReplayBatches = 4
+
+// bulkImport streams the relationships to the provided bulk import API.
+//
+// If SpiceDB reports that the API is unimplemented while the sent batches are
+// still kept for replay, fallback is true and the relationships that were
+// read from the source are returned so that they can be imported another way.
+func (c *Client) bulkImport(ctx context.Context, api API, source RelationshipSource, o *importOptions) (imported uint64, unsent []rel.Relationship, fallback bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.openImportStream(ctx, api)
+	if c.capabilities.checkUnimplemented(api, err) {
+		return 0, nil, true, nil
+	} else if err != nil {
+		return 0, nil, false, err
+	}
+
+	var (
+		replay   []rel.Relationship
+		replayed []uint64 // The progress of each batch kept for replay.
+		batches  int
+		sent     uint64
+	)
+	for {
+		batch, done, err := nextBatch(ctx, source, o.batchSize)
+		if err != nil {
+			return 0, nil, false, err
+		}
+
+		if len(batch) > 0 {
+			v1Rels := make([]*v1.Relationship, 0, len(batch))
+			for _, r := range batch {
+				v1Rels = append(v1Rels, r.V1Proto())
+			}
+
+			if err := stream.send(v1Rels); err != nil {
+				if !errors.Is(err, io.EOF) {
+					return 0, nil, false, err
+				}
+
+				// The stream was terminated and the reason is reported when it
+				// is closed.
+				_, err := stream.closeAndRecv()
+				if batches < importReplayBatches && c.capabilities.checkUnimplemented(api, err) {
+					return 0, append(replay, batch...), true, nil
+				}
+				return 0, nil, false, c.capabilities.unsupportedErr(err, api)
+			}
+
+			batches++
+			sent += uint64(len(batch))
+
+			// Progress for the batches kept for replay is only reported once
+			// they can no longer be imported by the fallback instead.
+			switch {
+			case batches < importReplayBatches:
+				replay = append(replay, batch...)
+				replayed = append(replayed, sent)
+			case batches == importReplayBatches:
+				for _, progress := range replayed {
+					o.reportProgress(progress)
+				}
+				replay, replayed = nil, nil
+				o.reportProgress(sent)
+			default:
+				o.reportProgress(sent)
+			}
+		}
+
+		if done {
+			break
+		}
+	}
+
+	numLoaded, err := stream.closeAndRecv()
+	if batches < importReplayBatches && c.capabilities.checkUnimplemented(api, err) {
+		return 0, replay, true, nil
+	} else if err != nil {
+		return 0, nil, false, c.capabilities.unsupportedErr(err, api)
+	}
+	for _, progress := range replayed {
+		o.reportProgress(progress)
+	}
+	return numLoaded, nil, false, nil
+}
+
+func (c *Client) touchImport(ctx context.Context, source RelationshipSource, o *importOptions) (uint64, error) {
+	var imported uint64
+	for {
+		batch, done, err := nextBatch(ctx, source, o.batchSize)
+		if err != nil {
+			return imported, err
+		}
+
+		if batch = dedupeBatch(batch); len(batch) > 0 {
+			var txn rel.Txn
+			for _, r := range batch {
+				txn.Touch(r)
+			}
+
+			// Touches are idempotent, so they can safely be retried.
+			if err := c.retryPolicy.do(ctx, func(cCtx context.Context) error {
+				_, err := c.client.WriteRelationships(cCtx, &v1.WriteRelationshipsRequest{
+					Updates: txn.V1Updates,
+				})
+				return err
+			}); err != nil {
+				return imported, err
+			}
+
+			imported += uint64(len(batch))
+			o.reportProgress(imported)
+		}
+
+		if done {
+			return imported, nil
+		}
+	}
+}
+
+// dedupeBatch removes relationships that appear more than once in a batch,
+// because SpiceDB rejects transactions that update a relationship twice.
+//
+// The last occurrence of a relationship wins, matching the outcome of
+// touching each of them in order.
+func dedupeBatch(batch []rel.Relationship) []rel.Relationship {
+	type key struct {
+		resourceType, resourceID, resourceRelation string
+		subjectType, subjectID, subjectRelation    string
+	}
+
+	indexes := make(map[key]int, len(batch))
+	deduped := batch[:0]
+	for _, r := range batch {
+		k := key{r.ResourceType, r.ResourceID, r.ResourceRelation, r.SubjectType, r.SubjectID, r.SubjectRelation}
+		if i, ok := indexes[k]; ok {
+			deduped[i] = r
+			continue
+		}
+		indexes[k] = len(deduped)
+		deduped = append(deduped, r)
+	}
+	return deduped
+}
+
+// prependSource returns a RelationshipSource that provides the relationships
+// before those of the source.
+func prependSource(rs []rel.Relationship, source RelationshipSource) RelationshipSource {
+	first := SourceFromSlice(rs)
+	return func(ctx context.Context) (rel.Relationship, error) {
+		r, err := first(ctx)
+		if errors.Is(err, io.EOF) {
+			return source(ctx)
+		}
+		return r, err
+	}
+}
+
+// nextBatch reads up to size relationships from the source.
+//
+// The returned bool is true once the source is exhausted.
+func nextBatch(ctx context.Context, source RelationshipSource, size int) ([]rel.Relationship, bool, error) {
+	batch := make([]rel.Relationship, 0, size)
+	for len(batch) < size {
+		r, err := source(ctx)
+		if errors.Is(err, io.EOF) {
+			return batch, true, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		batch = append(batch, r)
+	}
+	return batch, false, nil
+}
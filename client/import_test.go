@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// importServer implements bulk import unless unimplemented is set, in which
+// case it behaves like a SpiceDB that predates it. The experimental bulk
+// import API is only implemented if experimental is set.
+type importServer struct {
+	v1.UnimplementedPermissionsServiceServer
+	v1.UnimplementedExperimentalServiceServer
+
+	unimplemented bool
+	experimental  bool
+
+	mu       sync.Mutex
+	imported []*v1.Relationship
+	writes   int
+}
+
+func (s *importServer) ImportBulkRelationships(stream v1.PermissionsService_ImportBulkRelationshipsServer) error {
+	if s.unimplemented {
+		return status.Error(codes.Unimplemented, "unknown method")
+	}
+
+	loaded, err := s.load(func() ([]*v1.Relationship, error) {
+		req, err := stream.Recv()
+		return req.GetRelationships(), err
+	})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&v1.ImportBulkRelationshipsResponse{NumLoaded: loaded})
+}
+
+func (s *importServer) BulkImportRelationships(stream v1.ExperimentalService_BulkImportRelationshipsServer) error {
+	if !s.experimental {
+		return status.Error(codes.Unimplemented, "unknown method")
+	}
+
+	loaded, err := s.load(func() ([]*v1.Relationship, error) {
+		req, err := stream.Recv()
+		return req.GetRelationships(), err
+	})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&v1.BulkImportRelationshipsResponse{NumLoaded: loaded})
+}
+
+// load imports every batch received until the stream is closed.
+func (s *importServer) load(recv func() ([]*v1.Relationship, error)) (uint64, error) {
+	var loaded []*v1.Relationship
+	for {
+		rels, err := recv()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		loaded = append(loaded, rels...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.imported = append(s.imported, loaded...)
+	return uint64(len(loaded)), nil
+}
+
+func registerImportServer(srv *importServer) func(*grpc.Server) {
+	return func(s *grpc.Server) {
+		v1.RegisterPermissionsServiceServer(s, srv)
+		v1.RegisterExperimentalServiceServer(s, srv)
+	}
+}
+
+func (s *importServer) WriteRelationships(_ context.Context, req *v1.WriteRelationshipsRequest) (*v1.WriteRelationshipsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+
+	seen := make(map[string]struct{}, len(req.Updates))
+	for _, update := range req.Updates {
+		if update.Operation != v1.RelationshipUpdate_OPERATION_TOUCH {
+			return nil, fmt.Errorf("unexpected operation: %s", update.Operation)
+		}
+
+		r := rel.FromV1Proto(update.Relationship)
+		r.CaveatName, r.CaveatContext = "", nil
+		if _, ok := seen[r.String()]; ok {
+			return nil, status.Error(codes.InvalidArgument, "found more than one update for a relationship")
+		}
+		seen[r.String()] = struct{}{}
+		s.imported = append(s.imported, update.Relationship)
+	}
+	return &v1.WriteRelationshipsResponse{WrittenAt: &v1.ZedToken{Token: "written"}}, nil
+}
+
+func TestImportRelationships(t *testing.T) {
+	cases := []struct {
+		name           string
+		unimplemented  bool
+		experimental   bool
+		total          int
+		expectedWrites int
+		expectedReport string
+	}{
+		{"bulk", false, false, 25, 0, "[10 20 25]"},
+		{"bulk single batch", false, false, 5, 0, "[5]"},
+		{"experimental", true, true, 25, 0, "[10 20 25]"},
+		{"experimental single batch", true, true, 5, 0, "[5]"},
+		{"fallback", true, false, 25, 3, "[10 20 25]"},
+		{"fallback single batch", true, false, 5, 1, "[5]"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := &importServer{unimplemented: c.unimplemented, experimental: c.experimental}
+			client := newTestClient(t, registerImportServer(srv))
+
+			ch := make(chan rel.Relationship)
+			go func() {
+				defer close(ch)
+				for i := 0; i < c.total; i++ {
+					ch <- rel.MustFromTriple(fmt.Sprintf("document:%d", i), "viewer", "user:jzelinskie")
+				}
+			}()
+
+			var progress []uint64
+			imported, err := client.ImportRelationships(context.Background(), SourceFromChannel(ch),
+				WithImportBatchSize(10),
+				WithImportProgress(func(n uint64) { progress = append(progress, n) }),
+			)
+			if err != nil {
+				t.Fatal(err)
+			} else if imported != uint64(c.total) || len(srv.imported) != c.total {
+				t.Fatalf("expected %d relationships, imported %d and server received %d", c.total, imported, len(srv.imported))
+			} else if fmt.Sprint(progress) != c.expectedReport {
+				t.Fatalf("unexpected progress: %v", progress)
+			} else if srv.writes != c.expectedWrites {
+				t.Fatalf("expected %d writes, got %d", c.expectedWrites, srv.writes)
+			}
+
+			if supported := client.capabilities.supports(APIImportBulkRelationships); supported == c.unimplemented {
+				t.Fatalf("expected bulk import support to be recorded as %t", !c.unimplemented)
+			} else if supported := client.capabilities.supports(APIBulkImportRelationships); c.unimplemented && supported != c.experimental {
+				t.Fatalf("expected experimental bulk import support to be recorded as %t", c.experimental)
+			}
+		})
+	}
+}
+
+func TestImportRelationshipsFallbackDeduplicates(t *testing.T) {
+	srv := &importServer{unimplemented: true}
+	client := newTestClient(t, registerImportServer(srv))
+
+	first := rel.MustFromTriple("document:first", "viewer", "user:jzelinskie")
+	second := rel.MustFromTriple("document:second", "viewer", "user:jzelinskie")
+	caveated := first.WithCaveat("is_weekday", nil)
+
+	imported, err := client.ImportRelationships(context.Background(), SourceFromSlice([]rel.Relationship{first, second, caveated}))
+	if err != nil {
+		t.Fatal(err)
+	} else if imported != 2 || len(srv.imported) != 2 {
+		t.Fatalf("expected 2 relationships, imported %d and server received %d", imported, len(srv.imported))
+	} else if srv.imported[0].OptionalCaveat.GetCaveatName() != "is_weekday" {
+		t.Fatalf("expected the last duplicate to win, got %v", srv.imported[0])
+	}
+}
@@ -0,0 +1,280 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// lookupPageSize is the number of results requested per page when
+// transparently paginating through lookups.
+const lookupPageSize = 1_000
+
+// ResourceResult represents a single resource found by LookupResources.
+type ResourceResult struct {
+	Resource rel.Object
+
+	// Conditional is true when the resource only has the permission if the
+	// caveats that were missing context evaluate to true.
+	Conditional bool
+
+	// MissingCaveatFields contains the caveat context fields that would be
+	// required to fully evaluate a conditional result.
+	MissingCaveatFields []string
+
+	// LookedUpAt is the revision (ZedToken) at which the result was found.
+	LookedUpAt string
+}
+
+// ResourceFunc is called for each result of LookupResources.
+type ResourceFunc func(r *ResourceResult) error
+
+// LookupResources calls the provided function for each resource of the
+// provided type on which the subject has the provided permission.
+//
+// Results are paginated transparently and the lookup is resumed from the last
+// result received if a retriable error occurs.
+//
+// If deduplication is enabled, identical concurrent calls share a single
+// lookup; fully consistent calls only share a lookup that began after all of
+// them were made. Shared lookups keep every result in memory and only call
+// the function once the lookup has completed.
+func (c *Client) LookupResources(ctx context.Context, cs *consistency.Strategy, resourceType, permission string, subject rel.Objecter, caveatContext map[string]any, fn ResourceFunc) error {
+	v1Context, err := v1CaveatContext(caveatContext)
+	if err != nil {
+		return err
+	}
+
+	req := &v1.LookupResourcesRequest{
+		Consistency:        cs.V1Consistency,
+		ResourceObjectType: resourceType,
+		Permission:         permission,
+		Subject:            v1SubjectRef(subject.Object()),
+		Context:            v1Context,
+		OptionalLimit:      lookupPageSize,
+	}
+
+	if c.flights == nil {
+		return c.lookupResources(ctx, req, fn)
+	}
+
+	key, err := flightKey("LookupResources", req)
+	if err != nil {
+		return err
+	}
+
+	join := c.flights.do
+	if cs.V1Consistency.GetFullyConsistent() {
+		join = c.flights.doFresh
+	}
+	results, err := join(ctx, key, func(ctx context.Context) (any, error) {
+		var results []*ResourceResult
+		err := c.lookupResources(ctx, req, func(r *ResourceResult) error {
+			results = append(results, r)
+			return nil
+		})
+		return results, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results.([]*ResourceResult) {
+		// The results are shared, so each caller gets its own copy.
+		r := *r
+		r.MissingCaveatFields = slices.Clone(r.MissingCaveatFields)
+		if err := fn(&r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) lookupResources(ctx context.Context, req *v1.LookupResourcesRequest, fn ResourceFunc) error {
+	var cursor *v1.Cursor
+	for {
+		var pageCount int
+		if err := c.retryPolicy.do(ctx, func(cCtx context.Context) error {
+			pageReq := proto.Clone(req).(*v1.LookupResourcesRequest)
+			pageReq.OptionalCursor = cursor
+
+			stream, err := c.client.LookupResources(cCtx, pageReq)
+			if err != nil {
+				return err
+			}
+
+			for {
+				resp, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					return nil
+				} else if err != nil {
+					return err
+				}
+
+				if err := fn(&ResourceResult{
+					Resource:            rel.Object{Typ: req.ResourceObjectType, ID: resp.ResourceObjectId},
+					Conditional:         resp.Permissionship == v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION,
+					MissingCaveatFields: resp.PartialCaveatInfo.GetMissingRequiredContext(),
+					LookedUpAt:          resp.LookedUpAt.GetToken(),
+				}); err != nil {
+					return callbackError{err}
+				}
+				cursor = resp.AfterResultCursor
+				pageCount++
+			}
+		}); err != nil {
+			return unwrapCallbackError(err)
+		}
+
+		if pageCount < lookupPageSize {
+			return nil
+		}
+	}
+}
+
+func v1SubjectRef(o rel.Object) *v1.SubjectReference {
+	return &v1.SubjectReference{
+		Object: &v1.ObjectReference{
+			ObjectType: o.Typ,
+			ObjectId:   o.ID,
+		},
+		OptionalRelation: o.Relation,
+	}
+}
+
+func v1CaveatContext(caveatContext map[string]any) (*structpb.Struct, error) {
+	if caveatContext == nil {
+		return nil, nil
+	}
+	return structpb.NewStruct(caveatContext)
+}
+
+// SubjectResult represents a single subject found by LookupSubjects.
+type SubjectResult struct {
+	Subject rel.Object
+
+	// Conditional is true when the subject only has the permission if the
+	// caveats that were missing context evaluate to true.
+	Conditional bool
+
+	// MissingCaveatFields contains the caveat context fields that would be
+	// required to fully evaluate a conditional result.
+	MissingCaveatFields []string
+
+	// LookedUpAt is the revision (ZedToken) at which the result was found.
+	LookedUpAt string
+
+	// Excluded contains the subjects that are excluded from a wildcard result.
+	//
+	// This is always empty for results that are not wildcards.
+	Excluded []SubjectResult
+}
+
+// IsWildcard returns true if the result represents all subjects of the
+// looked up type (e.g. `user:*`).
+func (s SubjectResult) IsWildcard() bool { return s.Subject.ID == "*" }
+
+// SubjectFunc is called for each result of LookupSubjects.
+type SubjectFunc func(s *SubjectResult) error
+
+// LookupSubjects calls the provided function for each subject of the provided
+// type that has the provided permission on the resource.
+//
+// The optional subject relation can be empty string to lookup subjects
+// without a relation.
+//
+// The retry policy's AttemptTimeout bounds the wait for each result rather
+// than the entire lookup. If a retriable error occurs, the lookup is resumed
+// at the revision of the results already received and those results are
+// skipped. Attempts that received new results do not count towards the retry
+// policy's attempts. Skipping requires remembering the ID of every subject
+// received, so memory grows with the number of results.
+func (c *Client) LookupSubjects(ctx context.Context, cs *consistency.Strategy, resource rel.Objecter, permission, subjectType, optionalSubjectRelation string, caveatContext map[string]any, fn SubjectFunc) error {
+	v1Context, err := v1CaveatContext(caveatContext)
+	if err != nil {
+		return err
+	}
+
+	r := resource.Object()
+	req := &v1.LookupSubjectsRequest{
+		Consistency: cs.V1Consistency,
+		Resource: &v1.ObjectReference{
+			ObjectType: r.Typ,
+			ObjectId:   r.ID,
+		},
+		Permission:              permission,
+		SubjectObjectType:       subjectType,
+		OptionalSubjectRelation: optionalSubjectRelation,
+		Context:                 v1Context,
+	}
+
+	// There is no cursor to resume from, so the attempt timeout bounds the
+	// wait for each result rather than the entire lookup, which can take far
+	// longer.
+	policy := c.retryPolicy
+	policy.AttemptTimeout = 0
+
+	var lookedUpAt string
+	seen := make(map[string]struct{})
+	err = policy.doResumable(ctx, func(cCtx context.Context) (progressed bool, err error) {
+		cCtx, received, cancel := idleContext(cCtx, c.retryPolicy.AttemptTimeout)
+		defer cancel()
+
+		attemptReq := req
+		if lookedUpAt != "" {
+			attemptReq = proto.Clone(req).(*v1.LookupSubjectsRequest)
+			attemptReq.Consistency = &v1.Consistency{Requirement: &v1.Consistency_AtExactSnapshot{
+				AtExactSnapshot: &v1.ZedToken{Token: lookedUpAt},
+			}}
+		}
+
+		stream, err := c.client.LookupSubjects(cCtx, attemptReq)
+		if err != nil {
+			return false, idleErr(cCtx, err)
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return progressed, nil
+			} else if err != nil {
+				return progressed, idleErr(cCtx, err)
+			}
+			received()
+
+			lookedUpAt = resp.LookedUpAt.GetToken()
+			if _, ok := seen[resp.Subject.GetSubjectObjectId()]; ok {
+				continue
+			}
+			seen[resp.Subject.GetSubjectObjectId()] = struct{}{}
+			progressed = true
+
+			result := subjectResultFromV1(subjectType, optionalSubjectRelation, resp.Subject)
+			result.LookedUpAt = lookedUpAt
+			for _, excluded := range resp.ExcludedSubjects {
+				result.Excluded = append(result.Excluded, subjectResultFromV1(subjectType, optionalSubjectRelation, excluded))
+			}
+
+			if err := fn(&result); err != nil {
+				return progressed, callbackError{err}
+			}
+		}
+	})
+	return unwrapCallbackError(err)
+}
+
+func subjectResultFromV1(subjectType, subjectRelation string, s *v1.ResolvedSubject) SubjectResult {
+	return SubjectResult{
+		Subject:             rel.Object{Typ: subjectType, ID: s.SubjectObjectId, Relation: subjectRelation},
+		Conditional:         s.Permissionship == v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION,
+		MissingCaveatFields: s.PartialCaveatInfo.GetMissingRequiredContext(),
+	}
+}
@@ -0,0 +1,295 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// lookupServer paginates over a fixed number of resources and fails once in
+// the middle of the stream.
+type lookupServer struct {
+	v1.UnimplementedPermissionsServiceServer
+
+	total  int
+	failAt int
+
+	mu       sync.Mutex
+	failed   bool
+	requests int
+}
+
+func (s *lookupServer) LookupResources(req *v1.LookupResourcesRequest, stream v1.PermissionsService_LookupResourcesServer) error {
+	s.mu.Lock()
+	s.requests++
+	s.mu.Unlock()
+
+	start := 0
+	if req.OptionalCursor != nil {
+		start, _ = strconv.Atoi(req.OptionalCursor.Token)
+	}
+
+	for i := start; i < s.total && i < start+int(req.OptionalLimit); i++ {
+		s.mu.Lock()
+		fail := i == s.failAt && !s.failed
+		s.failed = s.failed || fail
+		s.mu.Unlock()
+		if fail {
+			return status.Error(codes.Unavailable, "dropped")
+		}
+
+		permissionship := v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION
+		if i%2 == 1 {
+			permissionship = v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+		}
+		if err := stream.Send(&v1.LookupResourcesResponse{
+			LookedUpAt:        &v1.ZedToken{Token: "looked"},
+			ResourceObjectId:  strconv.Itoa(i),
+			Permissionship:    permissionship,
+			AfterResultCursor: &v1.Cursor{Token: strconv.Itoa(i + 1)},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLookupResourcesPagination(t *testing.T) {
+	cases := []struct {
+		name             string
+		total, failAt    int
+		expectedRequests int
+	}{
+		{"single page", 10, -1, 1},
+		{"exactly one page", lookupPageSize, -1, 2},
+		{"multiple pages", 2*lookupPageSize + 5, -1, 3},
+		{"resumes after failure", 2*lookupPageSize + 5, lookupPageSize + 3, 4},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := &lookupServer{total: c.total, failAt: c.failAt}
+			client := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) })
+
+			var ids []string
+			if err := client.LookupResources(context.Background(), consistency.MinLatency(), "document", "view", rel.Object{Typ: "user", ID: "jzelinskie"}, nil, func(r *ResourceResult) error {
+				if r.Conditional != (len(ids)%2 == 1) {
+					return fmt.Errorf("unexpected permissionship for %s", r.Resource.ID)
+				} else if r.LookedUpAt != "looked" {
+					return fmt.Errorf("unexpected revision: %q", r.LookedUpAt)
+				}
+				ids = append(ids, r.Resource.ID)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(ids) != c.total {
+				t.Fatalf("expected %d results, got %d", c.total, len(ids))
+			}
+			for i, id := range ids {
+				if id != strconv.Itoa(i) {
+					t.Fatalf("unexpected result %d: %s", i, id)
+				}
+			}
+			if srv.requests != c.expectedRequests {
+				t.Fatalf("expected %d requests, got %d", c.expectedRequests, srv.requests)
+			}
+		})
+	}
+}
+
+func TestLookupResourcesStopsOnError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+	}{
+		{"error", errors.New("stop")},
+		{"retriable error", status.Error(codes.Unavailable, "stop")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := &lookupServer{total: 2 * lookupPageSize, failAt: -1}
+			client := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) })
+
+			var count int
+			err := client.LookupResources(context.Background(), consistency.MinLatency(), "document", "view", rel.Object{Typ: "user", ID: "jzelinskie"}, nil, func(*ResourceResult) error {
+				count++
+				if count == 3 {
+					return c.err
+				}
+				return nil
+			})
+			if err != c.err {
+				t.Fatalf("unexpected error: %v", err)
+			} else if count != 3 || srv.requests != 1 {
+				t.Fatalf("lookup continued after error: %d results, %d requests", count, srv.requests)
+			}
+		})
+	}
+}
+
+// subjectServer streams a fixed list of subjects and fails once after the
+// provided number of results.
+type subjectServer struct {
+	v1.UnimplementedPermissionsServiceServer
+
+	subjects []string
+	failAt   int
+	delay    time.Duration
+	stall    bool // Fail by waiting for the request to be cancelled.
+
+	mu          sync.Mutex
+	failed      bool
+	consistency []*v1.Consistency
+}
+
+func (s *subjectServer) LookupSubjects(req *v1.LookupSubjectsRequest, stream v1.PermissionsService_LookupSubjectsServer) error {
+	s.mu.Lock()
+	s.consistency = append(s.consistency, req.Consistency)
+	s.mu.Unlock()
+
+	for i, id := range s.subjects {
+		s.mu.Lock()
+		fail := i == s.failAt && !s.failed
+		s.failed = s.failed || fail
+		s.mu.Unlock()
+		if fail && s.stall {
+			<-stream.Context().Done()
+			return stream.Context().Err()
+		} else if fail {
+			return status.Error(codes.Unavailable, "dropped")
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-time.After(s.delay):
+		}
+
+		resp := &v1.LookupSubjectsResponse{
+			LookedUpAt: &v1.ZedToken{Token: "looked"},
+			Subject:    &v1.ResolvedSubject{SubjectObjectId: id},
+		}
+		if id == "*" {
+			resp.ExcludedSubjects = []*v1.ResolvedSubject{{SubjectObjectId: "banned"}}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLookupSubjects(t *testing.T) {
+	cases := []struct {
+		name             string
+		failAt           int
+		expectedRequests int
+	}{
+		{"no failure", -1, 1},
+		{"retries after failure", 2, 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := &subjectServer{subjects: []string{"a", "b", "*", "c"}, failAt: c.failAt}
+			client := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) })
+
+			var results []SubjectResult
+			if err := client.LookupSubjects(context.Background(), consistency.MinLatency(), rel.Object{Typ: "document", ID: "example"}, "view", "user", "", nil, func(s *SubjectResult) error {
+				results = append(results, *s)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(results) != len(srv.subjects) {
+				t.Fatalf("expected %d results, got %d", len(srv.subjects), len(results))
+			}
+			for i, r := range results {
+				if expected := (rel.Object{Typ: "user", ID: srv.subjects[i]}); r.Subject != expected {
+					t.Fatalf("unexpected result %d: %v", i, r.Subject)
+				}
+			}
+			if !results[2].IsWildcard() || len(results[2].Excluded) != 1 || results[2].Excluded[0].Subject.ID != "banned" {
+				t.Fatalf("unexpected wildcard result: %+v", results[2])
+			}
+
+			if len(srv.consistency) != c.expectedRequests {
+				t.Fatalf("expected %d requests, got %d", c.expectedRequests, len(srv.consistency))
+			}
+			for _, cs := range srv.consistency[1:] {
+				if cs.GetAtExactSnapshot().GetToken() != "looked" {
+					t.Fatalf("retry was not pinned to the first revision: %v", cs)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupSubjectsLongerThanAttemptTimeout(t *testing.T) {
+	srv := &subjectServer{subjects: []string{"a", "b", "c", "d", "e", "f"}, failAt: -1, delay: 20 * time.Millisecond}
+	client := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) },
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, AttemptTimeout: 50 * time.Millisecond}))
+
+	var ids []string
+	if err := client.LookupSubjects(context.Background(), consistency.MinLatency(), rel.Object{Typ: "document", ID: "example"}, "view", "user", "", nil, func(s *SubjectResult) error {
+		ids = append(ids, s.Subject.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprint(ids) != "[a b c d e f]" {
+		t.Fatalf("unexpected results: %v", ids)
+	} else if len(srv.consistency) != 1 {
+		t.Fatalf("expected a single request, got %d", len(srv.consistency))
+	}
+}
+
+func TestLookupSubjectsResumesStalledStream(t *testing.T) {
+	srv := &subjectServer{subjects: []string{"a", "b", "c", "d"}, failAt: 2, stall: true}
+	client := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) },
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1, AttemptTimeout: 50 * time.Millisecond}))
+
+	var ids []string
+	if err := client.LookupSubjects(context.Background(), consistency.MinLatency(), rel.Object{Typ: "document", ID: "example"}, "view", "user", "", nil, func(s *SubjectResult) error {
+		ids = append(ids, s.Subject.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprint(ids) != "[a b c d]" {
+		t.Fatalf("unexpected results: %v", ids)
+	} else if len(srv.consistency) != 2 {
+		t.Fatalf("expected the stalled stream to be resumed, got %d requests", len(srv.consistency))
+	}
+}
+
+func TestLookupSubjectsStopsOnError(t *testing.T) {
+	srv := &subjectServer{subjects: []string{"a", "b", "c"}, failAt: -1}
+	client := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) })
+
+	errStop := status.Error(codes.Unavailable, "stop")
+	var count int
+	err := client.LookupSubjects(context.Background(), consistency.MinLatency(), rel.Object{Typ: "document", ID: "example"}, "view", "user", "", nil, func(*SubjectResult) error {
+		count++
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("unexpected error: %v", err)
+	} else if count != 1 || len(srv.consistency) != 1 {
+		t.Fatalf("lookup continued after error: %d results, %d requests", count, len(srv.consistency))
+	}
+}
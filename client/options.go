@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip" // Register gzip compression
+	"google.golang.org/grpc/keepalive"
+
+	_ "github.com/mostynb/go-grpc-compression/experimental/s2" // Register Snappy S2 compression
+)
+
+// Compression is the name of a compression method supported by SpiceDB.
+type Compression string
+
+const (
+	// CompressionS2 is Snappy's S2 compression and performs best with SpiceDB.
+	CompressionS2 Compression = "s2"
+
+	// CompressionGzip is gzip compression.
+	CompressionGzip Compression = "gzip"
+
+	// CompressionNone disables compression.
+	CompressionNone Compression = ""
+)
+
+const (
+	defaultCheckChunkSize   = 500
+	defaultCheckConcurrency = 4
+	userAgent               = "gochugaru"
+)
+
+// Option configures a Client during construction.
+type Option func(*options)
+
+type options struct {
+	transport         grpc.DialOption
+	insecure          bool
+	credentials       Credentials
+	dialOpts          []grpc.DialOption
+	compression       Compression
+	userAgentSuffix   string
+	unaryInterceptors []grpc.UnaryClientInterceptor
+	streamInterceptor []grpc.StreamClientInterceptor
+	keepalive         *keepalive.ClientParameters
+	defaultTimeout    time.Duration
+	retryPolicy       RetryPolicy
+	checkChunkSize    int
+	checkConcurrency  int
+	batchWindow       time.Duration
+	batchMaxSize      int
+	checkCache        *CheckCache
+	deduplicate       bool
+}
+
+var defaultClientOpts = []Option{
+	WithCompression(CompressionS2),
+	WithRetryPolicy(DefaultRetryPolicy()),
+	WithCheckChunking(defaultCheckChunkSize, defaultCheckConcurrency),
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range defaultClientOpts {
+		opt(o)
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *options) grpcDialOpts() []grpc.DialOption {
+	ua := userAgent
+	if o.userAgentSuffix != "" {
+		ua += " " + o.userAgentSuffix
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithUserAgent(ua)}
+	if o.transport != nil {
+		dialOpts = append(dialOpts, o.transport)
+	}
+	if o.credentials != nil {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(perRPCCredentials{
+			creds:  o.credentials,
+			secure: !o.insecure,
+		}))
+	}
+	if o.compression != CompressionNone {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(string(o.compression))))
+	}
+	if o.keepalive != nil {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(*o.keepalive))
+	}
+
+	unary := o.unaryInterceptors
+	if o.defaultTimeout > 0 {
+		unary = append([]grpc.UnaryClientInterceptor{defaultTimeoutInterceptor(o.defaultTimeout)}, unary...)
+	}
+	if len(unary) > 0 {
+		dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(unary...))
+	}
+	if len(o.streamInterceptor) > 0 {
+		dialOpts = append(dialOpts, grpc.WithChainStreamInterceptor(o.streamInterceptor...))
+	}
+
+	return append(dialOpts, o.dialOpts...)
+}
+
+// defaultTimeoutInterceptor applies a timeout to unary requests whose context
+// does not already have a deadline.
+func defaultTimeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// withTransport configures the transport security of the connection.
+func withTransport(transport grpc.DialOption, insecure bool) Option {
+	return func(o *options) { o.transport, o.insecure = transport, insecure }
+}
+
+// withPresharedKey configures static credentials if a key was provided.
+func withPresharedKey(presharedKey string) Option {
+	return func(o *options) {
+		if presharedKey != "" {
+			o.credentials = StaticToken(presharedKey)
+		}
+	}
+}
+
+// WithCredentials configures the credentials used to authenticate requests,
+// replacing any preshared key provided to the constructor.
+//
+// Unless the client was created with NewPlaintext, credentials are only sent
+// over connections secured with TLS.
+func WithCredentials(creds Credentials) Option {
+	return func(o *options) { o.credentials = creds }
+}
+
+// WithDialOptions appends raw gRPC DialOptions used to connect to SpiceDB.
+//
+// This should only be used if the other options don't suffice.
+//
+// I'd love to hear about what DialOptions you're using in the SpiceDB Discord
+// (https://discord.gg/spicedb) or the issue tracker for this library.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) { o.dialOpts = append(o.dialOpts, opts...) }
+}
+
+// WithCompression configures the compression method used for requests.
+//
+// The default is CompressionS2.
+func WithCompression(c Compression) Option {
+	return func(o *options) { o.compression = c }
+}
+
+// WithUserAgent appends the provided suffix to the user agent of requests.
+func WithUserAgent(suffix string) Option {
+	return func(o *options) { o.userAgentSuffix = suffix }
+}
+
+// WithUnaryInterceptors appends interceptors for unary requests.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(o *options) { o.unaryInterceptors = append(o.unaryInterceptors, interceptors...) }
+}
+
+// WithStreamInterceptors appends interceptors for streaming requests.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(o *options) { o.streamInterceptor = append(o.streamInterceptor, interceptors...) }
+}
+
+// WithKeepalive configures keepalive pings for the connection to SpiceDB.
+func WithKeepalive(params keepalive.ClientParameters) Option {
+	return func(o *options) { o.keepalive = &params }
+}
+
+// WithDefaultTimeout bounds every unary request whose context doesn't
+// already have a deadline.
+//
+// Streaming requests (e.g. Watch) are unaffected.
+func WithDefaultTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.defaultTimeout = timeout }
+}
+
+// WithRetryPolicy configures how requests that fail with retriable errors
+// are retried.
+//
+// The default is DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) { o.retryPolicy = p }
+}
+
+// WithCheckChunking configures the maximum number of relationships sent in a
+// single check request and how many of these requests can be in-flight
+// concurrently for a single call.
+func WithCheckChunking(chunkSize, concurrency int) Option {
+	return func(o *options) {
+		o.checkChunkSize = max(chunkSize, 1)
+		o.checkConcurrency = max(concurrency, 1)
+	}
+}
+
+// WithCheckBatching enables transparently batching concurrent calls to
+// CheckOne and CheckOneDetailed.
+//
+// Calls sharing the same consistency strategy are collected for up to the
+// provided window or until the maximum batch size is reached and then sent
+// as a single batched check. A zero window disables batching.
+func WithCheckBatching(window time.Duration, maxBatchSize int) Option {
+	return func(o *options) { o.batchWindow, o.batchMaxSize = window, maxBatchSize }
+}
+
+// WithCheckCache enables serving check results from the provided cache.
+//
+// See CheckCache for the rules about which results can be served and
+// Client.WatchCheckCache for keeping the cache fresh.
+func WithCheckCache(cache *CheckCache) Option {
+	return func(o *options) { o.checkCache = cache }
+}
+
+// WithDeduplication configures whether identical concurrent calls to CheckOne,
+// CheckOneDetailed, LookupResources, and ReadSchema share a single request.
+//
+// Checks with full consistency are never deduplicated, because a shared
+// request may have been evaluated before a caller's preceding write. For the
+// same reason, fully consistent lookups and schema reads only share requests
+// that began after all of their callers.
+//
+// Deduplication is disabled by default.
+func WithDeduplication(enabled bool) Option {
+	return func(o *options) { o.deduplicate = enabled }
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/schema"
+)
+
+// ReflectSchema returns the structure of the current schema.
+//
+// If any filters are provided, only the items matching at least one of them
+// are returned.
+//
+// This uses an experimental API that may not be supported by all versions of
+// SpiceDB.
+func (c *Client) ReflectSchema(ctx context.Context, cs *consistency.Strategy, filters ...schema.Filter) (s *schema.Schema, readAtRevision string, err error) {
+	v1Filters := make([]*v1.ExpSchemaFilter, 0, len(filters))
+	for _, f := range filters {
+		v1Filters = append(v1Filters, f.V1Filter())
+	}
+
+	var resp *v1.ExperimentalReflectSchemaResponse
+	if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
+		resp, cErr = c.client.ExperimentalReflectSchema(cCtx, &v1.ExperimentalReflectSchemaRequest{
+			Consistency:     cs.V1Consistency,
+			OptionalFilters: v1Filters,
+		})
+		return cErr
+	}); err != nil {
+		return nil, "", c.capabilities.unsupportedErr(err, APIReflectSchema)
+	}
+
+	return schema.FromV1Proto(resp.Definitions, resp.Caveats), resp.ReadAt.GetToken(), nil
+}
+
+// DiffSchema returns the changes that would be made by replacing the current
+// schema with the provided schema.
+//
+// This can be used to preview a call to WriteSchema.
+//
+// This uses an experimental API that may not be supported by all versions of
+// SpiceDB.
+func (c *Client) DiffSchema(ctx context.Context, cs *consistency.Strategy, comparisonSchema string) (diffs []schema.Diff, readAtRevision string, err error) {
+	var resp *v1.ExperimentalDiffSchemaResponse
+	if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
+		resp, cErr = c.client.ExperimentalDiffSchema(cCtx, &v1.ExperimentalDiffSchemaRequest{
+			Consistency:      cs.V1Consistency,
+			ComparisonSchema: comparisonSchema,
+		})
+		return cErr
+	}); err != nil {
+		return nil, "", c.capabilities.unsupportedErr(err, APIDiffSchema)
+	}
+
+	diffs = make([]schema.Diff, 0, len(resp.Diffs))
+	for _, d := range resp.Diffs {
+		diffs = append(diffs, schema.DiffFromV1Proto(d))
+	}
+	return diffs, resp.ReadAt.GetToken(), nil
+}
+
+// ComputablePermissions returns the permissions that are computed, directly
+// or indirectly, from the provided relation (or permission) of a definition.
+//
+// The optional definition filter can be an empty string to return
+// permissions from all definitions.
+//
+// This uses an experimental API that may not be supported by all versions of
+// SpiceDB.
+func (c *Client) ComputablePermissions(ctx context.Context, cs *consistency.Strategy, definition, relation, optionalDefinitionFilter string) (permissions []schema.RelationReference, readAtRevision string, err error) {
+	var resp *v1.ExperimentalComputablePermissionsResponse
+	if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
+		resp, cErr = c.client.ExperimentalComputablePermissions(cCtx, &v1.ExperimentalComputablePermissionsRequest{
+			Consistency:                  cs.V1Consistency,
+			DefinitionName:               definition,
+			RelationName:                 relation,
+			OptionalDefinitionNameFilter: optionalDefinitionFilter,
+		})
+		return cErr
+	}); err != nil {
+		return nil, "", c.capabilities.unsupportedErr(err, APIComputablePermissions)
+	}
+
+	return relationReferencesFromV1(resp.Permissions), resp.ReadAt.GetToken(), nil
+}
+
+// DependentRelations returns the relations and permissions that are used,
+// directly or indirectly, to compute the provided permission of a definition.
+//
+// This uses an experimental API that may not be supported by all versions of
+// SpiceDB.
+func (c *Client) DependentRelations(ctx context.Context, cs *consistency.Strategy, definition, permission string) (relations []schema.RelationReference, readAtRevision string, err error) {
+	var resp *v1.ExperimentalDependentRelationsResponse
+	if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
+		resp, cErr = c.client.ExperimentalDependentRelations(cCtx, &v1.ExperimentalDependentRelationsRequest{
+			Consistency:    cs.V1Consistency,
+			DefinitionName: definition,
+			PermissionName: permission,
+		})
+		return cErr
+	}); err != nil {
+		return nil, "", c.capabilities.unsupportedErr(err, APIDependentRelations)
+	}
+
+	return relationReferencesFromV1(resp.Relations), resp.ReadAt.GetToken(), nil
+}
+
+func relationReferencesFromV1(refs []*v1.ExpRelationReference) []schema.RelationReference {
+	converted := make([]schema.RelationReference, 0, len(refs))
+	for _, ref := range refs {
+		converted = append(converted, schema.RelationReferenceFromV1Proto(ref))
+	}
+	return converted
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/schema"
+)
+
+type reflectionServer struct {
+	v1.UnimplementedExperimentalServiceServer
+}
+
+func (reflectionServer) ExperimentalReflectSchema(context.Context, *v1.ExperimentalReflectSchemaRequest) (*v1.ExperimentalReflectSchemaResponse, error) {
+	return &v1.ExperimentalReflectSchemaResponse{
+		Definitions: []*v1.ExpDefinition{{
+			Name: "document",
+			Relations: []*v1.ExpRelation{{
+				Name:                 "viewer",
+				ParentDefinitionName: "document",
+				SubjectTypes: []*v1.ExpTypeReference{
+					{SubjectDefinitionName: "user", Typeref: &v1.ExpTypeReference_IsTerminalSubject{IsTerminalSubject: true}},
+					{SubjectDefinitionName: "group", Typeref: &v1.ExpTypeReference_OptionalRelationName{OptionalRelationName: "member"}},
+				},
+			}},
+			Permissions: []*v1.ExpPermission{{Name: "view", ParentDefinitionName: "document"}},
+		}},
+		ReadAt: &v1.ZedToken{Token: "reflected"},
+	}, nil
+}
+
+func TestReflectSchema(t *testing.T) {
+	c := newTestClient(t, func(srv *grpc.Server) {
+		v1.RegisterExperimentalServiceServer(srv, reflectionServer{})
+	})
+
+	ctx := context.Background()
+	s, revision, err := c.ReflectSchema(ctx, consistency.Full())
+	if err != nil {
+		t.Fatal(err)
+	} else if revision != "reflected" {
+		t.Fatalf("unexpected revision: %q", revision)
+	}
+
+	def, ok := s.Definition("document")
+	if !ok {
+		t.Fatal("expected document definition")
+	} else if _, ok := def.Permission("view"); !ok {
+		t.Fatal("expected view permission")
+	}
+
+	viewer, ok := def.Relation("viewer")
+	if !ok {
+		t.Fatal("expected viewer relation")
+	} else if len(viewer.SubjectTypes) != 2 || viewer.SubjectTypes[1].String() != "group#member" {
+		t.Fatalf("unexpected subject types: %v", viewer.SubjectTypes)
+	}
+
+	_, _, err = c.DiffSchema(ctx, consistency.Full(), "definition user {}")
+	var unsupportedErr *UnsupportedAPIError
+	if !errors.As(err, &unsupportedErr) {
+		t.Fatalf("expected UnsupportedAPIError, got %v", err)
+	}
+}
+
+// schemaToolsServer answers the schema diff and relation graph APIs and
+// records the requests it receives.
+type schemaToolsServer struct {
+	v1.UnimplementedExperimentalServiceServer
+
+	mu       sync.Mutex
+	requests []proto.Message
+}
+
+func (s *schemaToolsServer) record(req proto.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+func (s *schemaToolsServer) ExperimentalDiffSchema(_ context.Context, req *v1.ExperimentalDiffSchemaRequest) (*v1.ExperimentalDiffSchemaResponse, error) {
+	s.record(req)
+	return &v1.ExperimentalDiffSchemaResponse{
+		Diffs: []*v1.ExpSchemaDiff{
+			{Diff: &v1.ExpSchemaDiff_DefinitionAdded{DefinitionAdded: &v1.ExpDefinition{Name: "user"}}},
+			{Diff: &v1.ExpSchemaDiff_RelationSubjectTypeAdded{RelationSubjectTypeAdded: &v1.ExpRelationSubjectTypeChange{
+				Relation:           &v1.ExpRelation{Name: "viewer", ParentDefinitionName: "document"},
+				ChangedSubjectType: &v1.ExpTypeReference{SubjectDefinitionName: "user", Typeref: &v1.ExpTypeReference_IsPublicWildcard{IsPublicWildcard: true}},
+			}}},
+		},
+		ReadAt: &v1.ZedToken{Token: "diffed"},
+	}, nil
+}
+
+func (s *schemaToolsServer) ExperimentalComputablePermissions(_ context.Context, req *v1.ExperimentalComputablePermissionsRequest) (*v1.ExperimentalComputablePermissionsResponse, error) {
+	s.record(req)
+	return &v1.ExperimentalComputablePermissionsResponse{
+		Permissions: []*v1.ExpRelationReference{
+			{DefinitionName: "document", RelationName: "view", IsPermission: true},
+			{DefinitionName: "folder", RelationName: "view", IsPermission: true},
+		},
+		ReadAt: &v1.ZedToken{Token: "computed"},
+	}, nil
+}
+
+func (s *schemaToolsServer) ExperimentalDependentRelations(_ context.Context, req *v1.ExperimentalDependentRelationsRequest) (*v1.ExperimentalDependentRelationsResponse, error) {
+	s.record(req)
+	return &v1.ExperimentalDependentRelationsResponse{
+		Relations: []*v1.ExpRelationReference{
+			{DefinitionName: "document", RelationName: "viewer"},
+			{DefinitionName: "document", RelationName: "edit", IsPermission: true},
+		},
+		ReadAt: &v1.ZedToken{Token: "dependent"},
+	}, nil
+}
+
+func TestSchemaTools(t *testing.T) {
+	srv := &schemaToolsServer{}
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterExperimentalServiceServer(s, srv) })
+	ctx := context.Background()
+
+	diffs, revision, err := c.DiffSchema(ctx, consistency.AtLeast("token"), "definition user {}")
+	if err != nil {
+		t.Fatal(err)
+	} else if revision != "diffed" {
+		t.Fatalf("unexpected revision: %q", revision)
+	}
+	expectedDiffs := []schema.Diff{
+		{Kind: schema.DefinitionAdded, Definition: &schema.Definition{Name: "user"}},
+		{
+			Kind:        schema.RelationSubjectTypeAdded,
+			Relation:    &schema.Relation{Name: "viewer", Definition: "document"},
+			SubjectType: &schema.SubjectType{Definition: "user", Wildcard: true},
+		},
+	}
+	if !reflect.DeepEqual(diffs, expectedDiffs) {
+		t.Fatalf("unexpected diffs: %+v", diffs)
+	}
+
+	permissions, revision, err := c.ComputablePermissions(ctx, consistency.MinLatency(), "document", "viewer", "folder")
+	if err != nil {
+		t.Fatal(err)
+	} else if revision != "computed" {
+		t.Fatalf("unexpected revision: %q", revision)
+	}
+	expectedPermissions := []schema.RelationReference{
+		{Definition: "document", Relation: "view", IsPermission: true},
+		{Definition: "folder", Relation: "view", IsPermission: true},
+	}
+	if !reflect.DeepEqual(permissions, expectedPermissions) {
+		t.Fatalf("unexpected permissions: %v", permissions)
+	}
+
+	relations, revision, err := c.DependentRelations(ctx, consistency.Full(), "document", "view")
+	if err != nil {
+		t.Fatal(err)
+	} else if revision != "dependent" {
+		t.Fatalf("unexpected revision: %q", revision)
+	}
+	expectedRelations := []schema.RelationReference{
+		{Definition: "document", Relation: "viewer"},
+		{Definition: "document", Relation: "edit", IsPermission: true},
+	}
+	if !reflect.DeepEqual(relations, expectedRelations) {
+		t.Fatalf("unexpected relations: %v", relations)
+	}
+
+	expectedRequests := []proto.Message{
+		&v1.ExperimentalDiffSchemaRequest{
+			Consistency:      consistency.AtLeast("token").V1Consistency,
+			ComparisonSchema: "definition user {}",
+		},
+		&v1.ExperimentalComputablePermissionsRequest{
+			Consistency:                  consistency.MinLatency().V1Consistency,
+			DefinitionName:               "document",
+			RelationName:                 "viewer",
+			OptionalDefinitionNameFilter: "folder",
+		},
+		&v1.ExperimentalDependentRelationsRequest{
+			Consistency:    consistency.Full().V1Consistency,
+			DefinitionName: "document",
+			PermissionName: "view",
+		},
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.requests) != len(expectedRequests) {
+		t.Fatalf("expected %d requests, got %d", len(expectedRequests), len(srv.requests))
+	}
+	for i, req := range srv.requests {
+		if !proto.Equal(req, expectedRequests[i]) {
+			t.Fatalf("unexpected request %d: %v", i, req)
+		}
+	}
+}
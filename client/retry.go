@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"golang.org/x/exp/slices"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy configures how requests that fail with retriable errors are
+// retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the delay grows after each retry.
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction (between 0 and 1) in
+	// either direction.
+	Jitter float64
+
+	// AttemptTimeout bounds the duration of each individual attempt.
+	//
+	// A zero timeout only bounds attempts by the caller's context.
+	AttemptTimeout time.Duration
+
+	// IsRetriable classifies which errors can be retried.
+	//
+	// IsRetriable is used if this is nil.
+	IsRetriable func(error) bool
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by clients unless otherwise
+// configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     backoff.DefaultMultiplier,
+		Jitter:         backoff.DefaultRandomizationFactor,
+		AttemptTimeout: 30 * time.Second,
+		IsRetriable:    IsRetriable,
+	}
+}
+
+// ErrMaxAttemptsExceeded is wrapped by the error returned when a request
+// exhausted all of the attempts allowed by its RetryPolicy.
+var ErrMaxAttemptsExceeded = errors.New("max attempts exceeded")
+
+// callbackError wraps an error returned by one of the caller's functions
+// while handling the results of a request so that the request is never
+// retried, even if the error would otherwise be retriable.
+type callbackError struct{ err error }
+
+func (e callbackError) Error() string { return e.err.Error() }
+
+// unwrapCallbackError returns the caller's error if err is a callbackError.
+func unwrapCallbackError(err error) error {
+	var cbErr callbackError
+	if errors.As(err, &cbErr) {
+		return cbErr.err
+	}
+	return err
+}
+
+// do calls the provided function until it succeeds, fails with an error that
+// is not retriable, or the policy has been exhausted.
+//
+// The returned error always wraps the last error returned by the function.
+// A callbackError is never retried.
+func (p RetryPolicy) do(ctx context.Context, fn func(context.Context) error) error {
+	isRetriable := p.isRetriable()
+
+	backoffInterval := backoff.NewExponentialBackOff()
+	backoffInterval.InitialInterval = p.InitialBackoff
+	backoffInterval.MaxInterval = p.MaxBackoff
+	backoffInterval.Multiplier = p.Multiplier
+	backoffInterval.RandomizationFactor = p.Jitter
+	backoffInterval.MaxElapsedTime = 0
+	backoffInterval.Reset()
+
+	maxAttempts := max(p.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		err := p.attempt(ctx, fn)
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, new(callbackError)), errors.As(err, new(resumeError)), !isRetriable(err):
+			return err
+		case ctx.Err() != nil:
+			return fmt.Errorf("%w while retrying: %w", ctx.Err(), err)
+		case attempt >= maxAttempts:
+			return fmt.Errorf("%w (%d): %w", ErrMaxAttemptsExceeded, maxAttempts, err)
+		}
+
+		timer := time.NewTimer(backoffInterval.NextBackOff())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w while retrying: %w", ctx.Err(), err)
+		}
+	}
+}
+
+func (p RetryPolicy) isRetriable() func(error) bool {
+	if p.IsRetriable == nil {
+		return IsRetriable
+	}
+	return p.IsRetriable
+}
+
+// resumeError is returned by an attempt of doResumable that made progress
+// before it failed, so that it is resumed without counting as a failure.
+type resumeError struct{ err error }
+
+func (e resumeError) Error() string { return e.err.Error() }
+
+// doResumable is like do for a stream that resumes from the position it
+// reached whenever the provided function is called again.
+//
+// The function reports whether its attempt made progress. An attempt that
+// made progress before failing with a retriable error or running out of time
+// is not counted as a failure and is resumed immediately.
+func (p RetryPolicy) doResumable(ctx context.Context, fn func(context.Context) (progressed bool, err error)) error {
+	isRetriable := p.isRetriable()
+	for {
+		err := p.do(ctx, func(cCtx context.Context) error {
+			progressed, err := fn(cCtx)
+			if err != nil && progressed && ctx.Err() == nil && !errors.As(err, new(callbackError)) && (isRetriable(err) || cCtx.Err() != nil) {
+				return resumeError{err}
+			}
+			return err
+		})
+
+		var resumeErr resumeError
+		if !errors.As(err, &resumeErr) {
+			return err
+		} else if ctx.Err() != nil {
+			return fmt.Errorf("%w while resuming: %w", ctx.Err(), resumeErr.err)
+		}
+	}
+}
+
+// idleContext returns a context that expires once the returned function has
+// not been called for the timeout.
+//
+// This bounds how long each message of a stream can take rather than the
+// entire stream. A zero timeout only bounds the stream by the parent context.
+func idleContext(ctx context.Context, timeout time.Duration) (idleCtx context.Context, received func(), cancel context.CancelFunc) {
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, func() {}, cancel
+	}
+
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(timeout, func() { cancelCause(context.DeadlineExceeded) })
+	return ctx, func() { timer.Reset(timeout) }, func() {
+		timer.Stop()
+		cancelCause(context.Canceled)
+	}
+}
+
+// idleErr marks an error caused by an idleContext expiring as a deadline
+// being exceeded, which is retriable, rather than a cancellation.
+func idleErr(idleCtx context.Context, err error) error {
+	if err != nil && idleCtx.Err() != nil && errors.Is(context.Cause(idleCtx), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+func (p RetryPolicy) attempt(ctx context.Context, fn func(context.Context) error) error {
+	if p.AttemptTimeout <= 0 {
+		return fn(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+	defer cancel()
+	return fn(attemptCtx)
+}
+
+// IsRetriable determines whether or not an error returned by the gRPC client
+// can be retried.
+//
+// This is the default classifier for a RetryPolicy and can be used to extend
+// it with additional errors.
+func IsRetriable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case isGrpcCode(err, codes.Unavailable, codes.DeadlineExceeded):
+		return true
+	case errContains(err, "retryable error", "try restarting transaction"):
+		return true // SpiceDB < v1.30 need this to properly retry.
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+func isGrpcCode(err error, codes ...codes.Code) bool {
+	if err == nil {
+		return false
+	}
+
+	if s, ok := status.FromError(err); ok {
+		return slices.Contains(codes, s.Code())
+	}
+	return false
+}
+
+func errContains(err error, errStrs ...string) bool {
+	if err == nil {
+		return false
+	}
+
+	for _, errStr := range errStrs {
+		if strings.Contains(err.Error(), errStr) {
+			return true
+		}
+	}
+	return false
+}
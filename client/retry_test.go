@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Multiplier:     1,
+	}
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	invalid := status.Error(codes.InvalidArgument, "invalid")
+
+	cases := []struct {
+		name             string
+		errs             []error
+		expectedAttempts int
+		expectedErr      error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"success after retries", []error{unavailable, unavailable, nil}, 3, nil},
+		{"not retriable", []error{invalid}, 1, invalid},
+		{"exhausted", []error{unavailable, unavailable, unavailable}, 3, ErrMaxAttemptsExceeded},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			attempts := 0
+			err := policy.do(context.Background(), func(context.Context) error {
+				err := c.errs[attempts]
+				attempts++
+				return err
+			})
+			if attempts != c.expectedAttempts {
+				t.Fatalf("expected %d attempts, got %d", c.expectedAttempts, attempts)
+			}
+			if !errors.Is(err, c.expectedErr) {
+				t.Fatalf("expected %v, got %v", c.expectedErr, err)
+			}
+			if err != nil && status.Code(err) != status.Code(c.errs[attempts-1]) {
+				t.Fatalf("expected error to wrap the last error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyContextCancellation(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := policy.do(ctx, func(context.Context) error {
+		cancel()
+		return status.Error(codes.Unavailable, "unavailable")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// flightGroup deduplicates identical in-flight calls.
+//
+// Unlike golang.org/x/sync/singleflight, every caller can be cancelled
+// independently: the shared call is only cancelled once all of its callers
+// have given up waiting on it.
+//
+// A nil flightGroup performs every call without deduplication.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	ctx     context.Context
+	fn      func(context.Context) (any, error)
+	done    chan struct{}
+	val     any
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+
+	// next is the call that is started once this one completes for the
+	// callers that must not share this one because it began before them.
+	next *flight
+}
+
+// do calls the function for the provided key unless an identical call is
+// already in-flight, in which case it waits for and returns its result.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) (any, error)) (any, error) {
+	return g.join(ctx, key, fn, false)
+}
+
+// doFresh is like do, but only shares calls that begin after it was called,
+// so that the result is as fresh as that of a call of its own.
+//
+// If an identical call is already in-flight, the next one is started once it
+// completes and is shared by every caller that arrived in the meantime.
+func (g *flightGroup) doFresh(ctx context.Context, key string, fn func(context.Context) (any, error)) (any, error) {
+	return g.join(ctx, key, fn, true)
+}
+
+func (g *flightGroup) join(ctx context.Context, key string, fn func(context.Context) (any, error), fresh bool) (any, error) {
+	if g == nil {
+		return fn(ctx)
+	}
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f, ok := g.calls[key]
+	switch {
+	case !ok:
+		f = &flight{ctx: ctx, fn: fn, done: make(chan struct{})}
+		g.startLocked(key, f)
+	case fresh:
+		if f.next == nil {
+			f.next = &flight{ctx: ctx, fn: fn, done: make(chan struct{})}
+		}
+		f = f.next
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 && f.cancel != nil {
+			f.cancel()
+			g.forgetLocked(key, f)
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// startLocked performs the call in the background and then starts the next
+// call if anybody is still waiting for it.
+func (g *flightGroup) startLocked(key string, f *flight) {
+	// The call outlives the caller that happened to start it, so it must only
+	// be cancelled once there is nobody waiting for it.
+	fctx, cancel := context.WithCancel(context.WithoutCancel(f.ctx))
+	f.cancel = cancel
+	g.calls[key] = f
+
+	go func() {
+		f.val, f.err = f.fn(fctx)
+		cancel()
+
+		g.mu.Lock()
+		g.forgetLocked(key, f)
+		if next := f.next; next != nil && next.waiters > 0 {
+			g.startLocked(key, next)
+		}
+		g.mu.Unlock()
+		close(f.done)
+	}()
+}
+
+func (g *flightGroup) forgetLocked(key string, f *flight) {
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+}
+
+// flightKey returns a key that uniquely identifies a request to a method.
+func flightKey(method string, msgs ...proto.Message) (string, error) {
+	key := []byte(method)
+	for _, msg := range msgs {
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return "", err
+		}
+		key = append(binary.AppendUvarint(key, uint64(len(b))), b...)
+	}
+	return string(key), nil
+}
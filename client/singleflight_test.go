@@ -0,0 +1,263 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"golang.org/x/exp/slices"
+	"google.golang.org/grpc"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+func TestFlightGroupDeduplicates(t *testing.T) {
+	var (
+		g       flightGroup
+		calls   atomic.Int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := g.do(context.Background(), "key", func(context.Context) (any, error) {
+				calls.Add(1)
+				<-release
+				return "result", nil
+			})
+			if err != nil || val != "result" {
+				t.Errorf("unexpected result: %v, %v", val, err)
+			}
+		}()
+	}
+
+	// Wait for every caller to join the flight before releasing it.
+	for {
+		g.mu.Lock()
+		f := g.calls["key"]
+		joined := f != nil && f.waiters == 10
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 call, got %d", calls.Load())
+	}
+}
+
+func TestFlightGroupCancellation(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err := g.do(ctx, "key", func(fctx context.Context) (any, error) {
+		close(started)
+		<-fctx.Done()
+		close(cancelled)
+		return nil, fctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+
+	// The shared call is cancelled once its only waiter has given up.
+	<-cancelled
+}
+
+// blockingCheckServer blocks every check until the release channel is closed.
+type blockingCheckServer struct {
+	checkServer
+
+	arrived chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingCheckServer) CheckBulkPermissions(ctx context.Context, req *v1.CheckBulkPermissionsRequest) (*v1.CheckBulkPermissionsResponse, error) {
+	s.arrived <- struct{}{}
+	<-s.release
+	return s.checkServer.CheckBulkPermissions(ctx, req)
+}
+
+func TestCheckOneNotDeduplicated(t *testing.T) {
+	cases := []struct {
+		name string
+		cs   *consistency.Strategy
+		opts []Option
+	}{
+		{"disabled by default", consistency.MinLatency(), nil},
+		{"full consistency", consistency.Full(), []Option{WithDeduplication(true)}},
+	}
+
+	const callers = 3
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := &blockingCheckServer{arrived: make(chan struct{}), release: make(chan struct{})}
+			c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) }, tc.opts...)
+
+			r := checkRels("allowed")[0]
+			var wg sync.WaitGroup
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := c.CheckOne(context.Background(), tc.cs, r); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+
+			// Every caller must reach the server while the others are still
+			// in-flight.
+			for i := 0; i < callers; i++ {
+				<-srv.arrived
+			}
+			close(srv.release)
+			wg.Wait()
+		})
+	}
+}
+
+func TestFlightGroupFresh(t *testing.T) {
+	var (
+		g       flightGroup
+		calls   atomic.Int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	fn := func(context.Context) (any, error) {
+		if n := calls.Add(1); n > 1 {
+			return n, nil
+		}
+		<-release
+		return int32(1), nil
+	}
+
+	results := make([]any, 3)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = g.doFresh(context.Background(), "key", fn)
+	}()
+	for calls.Load() == 0 {
+	}
+
+	// Callers arriving while the first call is in-flight share the next one.
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = g.doFresh(context.Background(), "key", fn)
+		}()
+	}
+	for {
+		g.mu.Lock()
+		next := g.calls["key"].next
+		joined := next != nil && next.waiters == 2
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls.Load())
+	} else if results[0] != int32(1) || results[1] != int32(2) || results[2] != int32(2) {
+		t.Fatalf("expected later callers to share a later call, got %v", results)
+	}
+}
+
+// blockingLookupServer blocks every lookup until the release channel is
+// closed.
+type blockingLookupServer struct {
+	lookupServer
+
+	arrived chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingLookupServer) LookupResources(req *v1.LookupResourcesRequest, stream v1.PermissionsService_LookupResourcesServer) error {
+	s.arrived <- struct{}{}
+	<-s.release
+	return s.lookupServer.LookupResources(req, stream)
+}
+
+func TestLookupResourcesDeduplicated(t *testing.T) {
+	srv := &blockingLookupServer{lookupServer: lookupServer{total: 3, failAt: -1}, arrived: make(chan struct{}, 2), release: make(chan struct{})}
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterPermissionsServiceServer(s, srv) }, WithDeduplication(true))
+
+	const callers = 3
+	var wg sync.WaitGroup
+	found := make([][]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.LookupResources(context.Background(), consistency.MinLatency(), "document", "view", rel.Object{Typ: "user", ID: "jzelinskie"}, nil, func(r *ResourceResult) error {
+				found[i] = append(found[i], r.Resource.ID)
+				return nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// Wait for every caller to join the lookup before releasing it.
+	<-srv.arrived
+	for {
+		c.flights.mu.Lock()
+		joined := 0
+		for _, f := range c.flights.calls {
+			joined += f.waiters
+		}
+		c.flights.mu.Unlock()
+		if joined == callers {
+			break
+		}
+	}
+	close(srv.release)
+	wg.Wait()
+
+	if srv.requests != 1 {
+		t.Fatalf("expected a single lookup, got %d", srv.requests)
+	}
+	for _, ids := range found {
+		if !slices.Equal(ids, []string{"0", "1", "2"}) {
+			t.Fatalf("unexpected results: %v", found)
+		}
+	}
+}
+
+func TestReadSchemaDeduplicatedOnlyConcurrently(t *testing.T) {
+	srv := &countingSchemaServer{}
+	c := newTestClient(t, func(s *grpc.Server) { v1.RegisterSchemaServiceServer(s, srv) }, WithDeduplication(true))
+
+	for i := 0; i < 2; i++ {
+		if _, revision, err := c.ReadSchema(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if revision != "rev" {
+			t.Fatalf("unexpected revision: %q", revision)
+		}
+	}
+	if reads := srv.reads.Load(); reads != 2 {
+		t.Fatalf("expected sequential reads not to be shared, got %d reads", reads)
+	}
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// ErrInvalidCA is returned when a certificate authority contains no valid
+// PEM-encoded certificates.
+var ErrInvalidCA = errors.New("invalid certificate authority: no PEM-encoded certificates found")
+
+// NewCustomCA creates a client using TLS verified by the provided
+// PEM-encoded certificate authority instead of the operating system's.
+//
+// This should be used for clusters with certificates issued by an internal
+// certificate authority.
+func NewCustomCA(endpoint, presharedKey string, caPEM []byte, opts ...Option) (*Client, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, ErrInvalidCA
+	}
+
+	return New(endpoint, append([]Option{
+		withTransport(grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		})), false),
+		withPresharedKey(presharedKey),
+	}, opts...)...)
+}
+
+// NewMutualTLS creates a client using mutual TLS: the client presents the
+// provided certificate and verifies the server using the provided
+// certificate authority.
+//
+// All files are PEM-encoded and are reloaded whenever they change on disk, so
+// certificates can be rotated without recreating the client. An empty caFile
+// verifies the server using the operating system's certificate chain.
+func NewMutualTLS(endpoint, presharedKey, certFile, keyFile, caFile string, opts ...Option) (*Client, error) {
+	r := &certReloader{serverName: endpointHost(endpoint), certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return New(endpoint, append([]Option{
+		withTransport(grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			MinVersion:           tls.VersionTLS12,
+			GetClientCertificate: r.clientCertificate,
+			// The default verification is replaced by VerifyConnection so that
+			// it always uses the most recently loaded certificate authority.
+			InsecureSkipVerify: true,
+			VerifyConnection:   r.verifyConnection,
+		})), false),
+		withPresharedKey(presharedKey),
+	}, opts...)...)
+}
+
+// endpointHost returns the host of a gRPC endpoint (e.g. "dns:///host:port"),
+// which is the name the server's certificate must be valid for.
+func endpointHost(endpoint string) string {
+	if _, target, ok := strings.Cut(endpoint, "://"); ok {
+		// The authority, if any, precedes the endpoint of the target.
+		_, endpoint, _ = strings.Cut(target, "/")
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+	return strings.Trim(endpoint, "[]")
+}
+
+// certReloader holds a client certificate and certificate authority that are
+// reloaded from disk when their files change.
+type certReloader struct {
+	// serverName is the DNS name or IP address that the server's certificate
+	// is verified against.
+	serverName string
+
+	certFile, keyFile, caFile string
+
+	mu       sync.Mutex
+	modTimes [3]time.Time
+	cert     *tls.Certificate
+	roots    *x509.CertPool
+}
+
+func (r *certReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+func (r *certReloader) verifyConnection(cs tls.ConnectionState) error {
+	if err := r.reload(); err != nil {
+		return err
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server presented no certificates")
+	} else if r.serverName == "" {
+		return errors.New("no server name to verify the server's certificate against")
+	}
+
+	r.mu.Lock()
+	roots := r.roots
+	r.mu.Unlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		// ServerName is empty when connecting to an IP address, so the
+		// name is taken from the endpoint. IP addresses are verified
+		// against the certificate's IP SANs.
+		DNSName:       r.serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// reload loads the files from disk if any of them have been modified since
+// they were last loaded.
+//
+// Once loaded successfully, failures to reload (e.g. a certificate that was
+// written to disk before its key) are ignored and the previously loaded files
+// remain in use until a reload succeeds.
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	loaded := r.cert != nil
+	r.mu.Unlock()
+
+	if err := r.reloadFiles(); err != nil && !loaded {
+		return err
+	}
+	return nil
+}
+
+func (r *certReloader) reloadFiles() error {
+	var modTimes [3]time.Time
+	for i, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil && modTimes == r.modTimes {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var roots *x509.CertPool
+	if r.caFile == "" {
+		if roots, err = x509.SystemCertPool(); err != nil {
+			return err
+		}
+	} else {
+		caPEM, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return ErrInvalidCA
+		}
+	}
+
+	r.cert, r.roots, r.modTimes = &cert, roots, modTimes
+	return nil
+}
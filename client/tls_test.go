@@ -0,0 +1,270 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// testCert is a generated certificate and its PEM encodings.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert generates a certificate for localhost that is signed by the
+// provided parent or is a self-signed CA if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	return newTestCertForHosts(t, name, parent, "localhost", "127.0.0.1")
+}
+
+// newTestCertForHosts is newTestCert for the provided DNS names and IP
+// addresses.
+func newTestCertForHosts(t *testing.T, name string, parent *testCert, hosts ...string) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeFiles writes the provided contents with a distinct modification time
+// so that they are detected as changed.
+func writeFiles(t *testing.T, modTime time.Time, files map[string][]byte) {
+	t.Helper()
+	for path, contents := range files {
+		if err := os.WriteFile(path, contents, 0o600); err != nil {
+			t.Fatal(err)
+		} else if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNewCustomCAInvalid(t *testing.T) {
+	if _, err := NewCustomCA("localhost:50051", "", []byte("not a certificate")); !errors.Is(err, ErrInvalidCA) {
+		t.Fatalf("expected ErrInvalidCA, got %v", err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	ca := newTestCert(t, "ca", nil)
+	first := newTestCert(t, "first", ca)
+	now := time.Now()
+	writeFiles(t, now.Add(-time.Hour), map[string][]byte{certFile: first.certPEM, keyFile: first.keyPEM, caFile: ca.certPEM})
+
+	r := &certReloader{serverName: "localhost", certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	otherCA := newTestCert(t, "other-ca", nil)
+	second := newTestCert(t, "second", otherCA)
+	cases := []struct {
+		name             string
+		files            map[string][]byte
+		expectedCert     string
+		expectedVerified *testCert
+		expectedRejected *testCert
+	}{
+		{"initial files", nil, "first", first, second},
+		{"rotated certificate and CA", map[string][]byte{certFile: second.certPEM, keyFile: second.keyPEM, caFile: otherCA.certPEM}, "second", second, first},
+		{"mismatched key keeps last files", map[string][]byte{keyFile: first.keyPEM}, "second", second, first},
+		{"invalid CA keeps last files", map[string][]byte{keyFile: second.keyPEM, caFile: []byte("garbage")}, "second", second, first},
+	}
+
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			writeFiles(t, now.Add(time.Duration(i)*time.Minute), c.files)
+
+			cert, err := r.clientCertificate(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			} else if leaf.Subject.CommonName != c.expectedCert {
+				t.Fatalf("expected certificate %q, got %q", c.expectedCert, leaf.Subject.CommonName)
+			}
+
+			state := func(tc *testCert) tls.ConnectionState {
+				return tls.ConnectionState{PeerCertificates: []*x509.Certificate{tc.cert}}
+			}
+			if err := r.verifyConnection(state(c.expectedVerified)); err != nil {
+				t.Fatalf("expected server certificate to be verified: %v", err)
+			}
+			if err := r.verifyConnection(state(c.expectedRejected)); err == nil {
+				t.Fatal("expected server certificate from another CA to be rejected")
+			}
+		})
+	}
+}
+
+func TestEndpointHost(t *testing.T) {
+	cases := map[string]string{
+		"localhost:50051":         "localhost",
+		"127.0.0.1:50051":         "127.0.0.1",
+		"[::1]:50051":             "::1",
+		"spicedb.example":         "spicedb.example",
+		"dns:///spicedb:50051":    "spicedb",
+		"dns://8.8.8.8/spicedb:1": "spicedb",
+	}
+	for endpoint, expected := range cases {
+		if host := endpointHost(endpoint); host != expected {
+			t.Errorf("%s: expected %q, got %q", endpoint, expected, host)
+		}
+	}
+}
+
+func TestNewMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	ca := newTestCert(t, "ca", nil)
+	client := newTestCert(t, "client", ca)
+	writeFiles(t, time.Now(), map[string][]byte{certFile: client.certPEM, keyFile: client.keyPEM, caFile: ca.certPEM})
+
+	if _, err := NewMutualTLS("localhost:50051", "", certFile, keyFile, filepath.Join(dir, "missing.crt")); err == nil {
+		t.Fatal("expected an error for a missing CA file")
+	}
+
+	cases := []struct {
+		name     string
+		host     string
+		server   *testCert
+		expected bool
+	}{
+		{"hostname", "localhost", newTestCert(t, "server", ca), true},
+		{"IP address", "127.0.0.1", newTestCert(t, "server", ca), true},
+		{"hostname not in certificate", "localhost", newTestCertForHosts(t, "server", ca, "evil.example"), false},
+		{"IP address not in certificate", "127.0.0.1", newTestCertForHosts(t, "server", ca, "evil.example"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			port := serveMutualTLS(t, c.server, ca)
+
+			client, err := NewMutualTLS(net.JoinHostPort(c.host, port), "", certFile, keyFile, caFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			_, revision, err := client.ReadSchema(context.Background())
+			switch {
+			case c.expected && err != nil:
+				t.Fatal(err)
+			case c.expected && revision != "rev":
+				t.Fatalf("unexpected revision: %q", revision)
+			case !c.expected && err == nil:
+				t.Fatal("expected the server's certificate to be rejected")
+			}
+		})
+	}
+}
+
+// serveMutualTLS serves a schema on 127.0.0.1 using the server certificate
+// and requires clients to present a certificate signed by the CA.
+//
+// Returns the port being served on.
+func serveMutualTLS(t *testing.T, server, ca *testCert) string {
+	t.Helper()
+
+	serverCert, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	v1.RegisterSchemaServiceServer(srv, versionedSchemaServer{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return portOf(t, lis)
+}
+
+func portOf(t *testing.T, lis net.Listener) string {
+	t.Helper()
+	_, port, err := net.SplitHostPort(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
@@ -0,0 +1,291 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/authzed/authzed-go/pkg/requestmeta"
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+// ErrMissingTrace is returned when SpiceDB did not return debug information
+// for a check that requested it.
+var ErrMissingTrace = errors.New("SpiceDB did not return a check trace")
+
+// CaveatResult is the outcome of evaluating a caveat expression.
+type CaveatResult int
+
+const (
+	// CaveatUnevaluated means that the caveat was not evaluated.
+	CaveatUnevaluated CaveatResult = iota
+
+	// CaveatFalse means that the caveat evaluated to false.
+	CaveatFalse
+
+	// CaveatTrue means that the caveat evaluated to true.
+	CaveatTrue
+
+	// CaveatMissingContext means that the caveat could not be fully evaluated
+	// because some of its context was not provided.
+	CaveatMissingContext
+)
+
+func (r CaveatResult) String() string {
+	switch r {
+	case CaveatUnevaluated:
+		return "unevaluated"
+	case CaveatFalse:
+		return "false"
+	case CaveatTrue:
+		return "true"
+	case CaveatMissingContext:
+		return "missing_context"
+	}
+	return "unknown"
+}
+
+// CaveatEvaluation describes a caveat expression that was evaluated while
+// resolving a check.
+type CaveatEvaluation struct {
+	// Name is the name of the caveat, if the expression belonged to one.
+	Name       string
+	Expression string
+	Result     CaveatResult
+
+	// Context contains the named values used to evaluate the expression.
+	Context map[string]any
+
+	// MissingCaveatFields contains the context fields that would be required
+	// to fully evaluate the expression.
+	MissingCaveatFields []string
+}
+
+// CheckTrace is a node in the tree of sub-problems that SpiceDB resolved in
+// order to answer a check.
+type CheckTrace struct {
+	Resource rel.Object
+
+	// Permission is the name of the permission or relation being checked.
+	Permission string
+
+	// IsRelation is true when Permission refers to a relation rather than a
+	// permission.
+	IsRelation bool
+
+	Subject        rel.Object
+	Permissionship Permissionship
+
+	// Caveat is set when a caveat was evaluated at this step.
+	Caveat *CaveatEvaluation
+
+	// Cached is true when the result was served from SpiceDB's cache rather
+	// than by resolving sub-problems.
+	Cached bool
+
+	Duration    time.Duration
+	SubProblems []*CheckTrace
+}
+
+// Walk calls the provided function for the trace and each of its descendants
+// in depth-first order, stopping at the first error.
+func (t *CheckTrace) Walk(fn func(t *CheckTrace, depth int) error) error {
+	return t.walk(fn, 0)
+}
+
+func (t *CheckTrace) walk(fn func(t *CheckTrace, depth int) error, depth int) error {
+	if err := fn(t, depth); err != nil {
+		return err
+	}
+	for _, sub := range t.SubProblems {
+		if err := sub.walk(fn, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTo writes the trace as indented text with one sub-problem per line.
+func (t *CheckTrace) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	err := t.Walk(func(t *CheckTrace, depth int) error {
+		indent := strings.Repeat("  ", depth)
+		n, err := fmt.Fprintf(w, "%s%s\n", indent, t.line())
+		written += int64(n)
+		if err != nil || t.Caveat == nil {
+			return err
+		}
+
+		n, err = fmt.Fprintf(w, "%s  %s\n", indent, t.Caveat.line())
+		written += int64(n)
+		return err
+	})
+	return written, err
+}
+
+// String returns the trace as indented text suitable for logging.
+func (t *CheckTrace) String() string {
+	var b strings.Builder
+	_, _ = t.WriteTo(&b)
+	return b.String()
+}
+
+func (t *CheckTrace) line() string {
+	kind := "permission"
+	if t.IsRelation {
+		kind = "relation"
+	}
+
+	details := []string{kind}
+	if t.Cached {
+		details = append(details, "cached")
+	}
+	if t.Duration > 0 {
+		details = append(details, t.Duration.String())
+	}
+
+	return fmt.Sprintf("%s#%s@%s: %s (%s)",
+		objectString(t.Resource),
+		t.Permission,
+		objectString(t.Subject),
+		t.Permissionship,
+		strings.Join(details, ", "),
+	)
+}
+
+func (e *CaveatEvaluation) line() string {
+	var b strings.Builder
+	b.WriteString("caveat")
+	if e.Name != "" {
+		b.WriteString(" " + e.Name)
+	}
+	fmt.Fprintf(&b, ": %s", e.Result)
+	if e.Expression != "" {
+		fmt.Fprintf(&b, " `%s`", e.Expression)
+	}
+	if len(e.Context) > 0 {
+		fmt.Fprintf(&b, " context=%v", e.Context)
+	}
+	if len(e.MissingCaveatFields) > 0 {
+		fmt.Fprintf(&b, " missing=%v", e.MissingCaveatFields)
+	}
+	return b.String()
+}
+
+func objectString(o rel.Object) string {
+	s := o.Typ + ":" + o.ID
+	if o.Relation != "" {
+		s += "#" + o.Relation
+	}
+	return s
+}
+
+// CheckWithTrace checks a single relationship and returns the trace of how
+// SpiceDB computed the result.
+//
+// Tracing is expensive for SpiceDB, so this should only be used for
+// debugging. Traced checks are never cached, batched, or deduplicated.
+func (c *Client) CheckWithTrace(ctx context.Context, cs *consistency.Strategy, r rel.Interface) (CheckResult, *CheckTrace, error) {
+	item := v1CheckItem(r)
+
+	var resp *v1.CheckPermissionResponse
+	var trailer metadata.MD
+	if err := c.retryPolicy.do(ctx, func(cCtx context.Context) (cErr error) {
+		// Older versions of SpiceDB only return debug information in the
+		// trailer when it is requested by a header.
+		cCtx = requestmeta.AddRequestHeaders(cCtx, requestmeta.RequestDebugInformation)
+		resp, cErr = c.client.CheckPermission(cCtx, &v1.CheckPermissionRequest{
+			Consistency: cs.V1Consistency,
+			Resource:    item.Resource,
+			Permission:  item.Permission,
+			Subject:     item.Subject,
+			Context:     item.Context,
+			WithTracing: true,
+		}, grpc.Trailer(&trailer))
+		return cErr
+	}); err != nil {
+		return CheckResult{}, nil, err
+	}
+
+	result := checkResultFromV1(resp.Permissionship, resp.PartialCaveatInfo, resp.CheckedAt.GetToken())
+
+	debug := resp.DebugTrace
+	if debug == nil {
+		encoded := trailer.Get(string(responsemeta.DebugInformation))
+		if len(encoded) == 0 {
+			return result, nil, ErrMissingTrace
+		}
+
+		debug = &v1.DebugInformation{}
+		if err := protojson.Unmarshal([]byte(encoded[0]), debug); err != nil {
+			return result, nil, fmt.Errorf("failed to parse check trace: %w", err)
+		}
+	}
+	if debug.Check == nil {
+		return result, nil, ErrMissingTrace
+	}
+
+	return result, checkTraceFromV1(debug.Check), nil
+}
+
+func checkTraceFromV1(t *v1.CheckDebugTrace) *CheckTrace {
+	trace := &CheckTrace{
+		Resource: rel.Object{
+			Typ: t.Resource.GetObjectType(),
+			ID:  t.Resource.GetObjectId(),
+		},
+		Permission: t.Permission,
+		IsRelation: t.PermissionType == v1.CheckDebugTrace_PERMISSION_TYPE_RELATION,
+		Subject: rel.Object{
+			Typ:      t.Subject.GetObject().GetObjectType(),
+			ID:       t.Subject.GetObject().GetObjectId(),
+			Relation: t.Subject.GetOptionalRelation(),
+		},
+		Duration: t.Duration.AsDuration(),
+	}
+
+	switch t.Result {
+	case v1.CheckDebugTrace_PERMISSIONSHIP_HAS_PERMISSION:
+		trace.Permissionship = HasPermission
+	case v1.CheckDebugTrace_PERMISSIONSHIP_CONDITIONAL_PERMISSION:
+		trace.Permissionship = ConditionalPermission
+	}
+
+	if info := t.CaveatEvaluationInfo; info != nil {
+		trace.Caveat = &CaveatEvaluation{
+			Name:                info.CaveatName,
+			Expression:          info.Expression,
+			Context:             info.Context.AsMap(),
+			MissingCaveatFields: info.PartialCaveatInfo.GetMissingRequiredContext(),
+		}
+		switch info.Result {
+		case v1.CaveatEvalInfo_RESULT_FALSE:
+			trace.Caveat.Result = CaveatFalse
+		case v1.CaveatEvalInfo_RESULT_TRUE:
+			trace.Caveat.Result = CaveatTrue
+		case v1.CaveatEvalInfo_RESULT_MISSING_SOME_CONTEXT:
+			trace.Caveat.Result = CaveatMissingContext
+		}
+	}
+
+	switch resolution := t.Resolution.(type) {
+	case *v1.CheckDebugTrace_WasCachedResult:
+		trace.Cached = resolution.WasCachedResult
+	case *v1.CheckDebugTrace_SubProblems_:
+		for _, sub := range resolution.SubProblems.GetTraces() {
+			trace.SubProblems = append(trace.SubProblems, checkTraceFromV1(sub))
+		}
+	}
+
+	return trace
+}
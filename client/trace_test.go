@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/jzelinskie/gochugaru/consistency"
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+type tracingPermissionsServer struct {
+	v1.UnimplementedPermissionsServiceServer
+}
+
+func (tracingPermissionsServer) CheckPermission(_ context.Context, req *v1.CheckPermissionRequest) (*v1.CheckPermissionResponse, error) {
+	resp := &v1.CheckPermissionResponse{
+		CheckedAt:      &v1.ZedToken{Token: "checked"},
+		Permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+	}
+	if !req.WithTracing {
+		return resp, nil
+	}
+
+	caveatContext, err := structpb.NewStruct(map[string]any{"day": "monday"})
+	if err != nil {
+		return nil, err
+	}
+
+	resp.DebugTrace = &v1.DebugInformation{Check: &v1.CheckDebugTrace{
+		Resource:       req.Resource,
+		Permission:     req.Permission,
+		PermissionType: v1.CheckDebugTrace_PERMISSION_TYPE_PERMISSION,
+		Subject:        req.Subject,
+		Result:         v1.CheckDebugTrace_PERMISSIONSHIP_HAS_PERMISSION,
+		Resolution: &v1.CheckDebugTrace_SubProblems_{SubProblems: &v1.CheckDebugTrace_SubProblems{
+			Traces: []*v1.CheckDebugTrace{
+				{
+					Resource:       req.Resource,
+					Permission:     "viewer",
+					PermissionType: v1.CheckDebugTrace_PERMISSION_TYPE_RELATION,
+					Subject:        req.Subject,
+					Result:         v1.CheckDebugTrace_PERMISSIONSHIP_NO_PERMISSION,
+					Resolution:     &v1.CheckDebugTrace_WasCachedResult{WasCachedResult: true},
+				},
+				{
+					Resource:       req.Resource,
+					Permission:     "weekday_viewer",
+					PermissionType: v1.CheckDebugTrace_PERMISSION_TYPE_RELATION,
+					Subject:        req.Subject,
+					Result:         v1.CheckDebugTrace_PERMISSIONSHIP_HAS_PERMISSION,
+					CaveatEvaluationInfo: &v1.CaveatEvalInfo{
+						CaveatName: "is_weekday",
+						Expression: `day != "sunday"`,
+						Result:     v1.CaveatEvalInfo_RESULT_TRUE,
+						Context:    caveatContext,
+					},
+				},
+			},
+		}},
+	}}
+	return resp, nil
+}
+
+func TestCheckWithTrace(t *testing.T) {
+	c := newTestClient(t, func(srv *grpc.Server) {
+		v1.RegisterPermissionsServiceServer(srv, tracingPermissionsServer{})
+	})
+
+	result, trace, err := c.CheckWithTrace(context.Background(), consistency.Full(), rel.MustFromTriple("document:example", "view", "user:jzelinskie"))
+	if err != nil {
+		t.Fatal(err)
+	} else if !result.Allowed() || result.CheckedAt != "checked" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	expected := `document:example#view@user:jzelinskie: has_permission (permission)
+  document:example#viewer@user:jzelinskie: no_permission (relation, cached)
+  document:example#weekday_viewer@user:jzelinskie: has_permission (relation)
+    caveat is_weekday: true ` + "`" + `day != "sunday"` + "`" + ` context=map[day:monday]
+`
+	if got := trace.String(); got != expected {
+		t.Fatalf("unexpected trace:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	var cached int
+	_ = trace.Walk(func(t *CheckTrace, _ int) error {
+		if t.Cached {
+			cached++
+		}
+		return nil
+	})
+	if cached != 1 {
+		t.Fatalf("expected 1 cached sub-problem, got %d", cached)
+	}
+}
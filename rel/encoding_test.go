@@ -0,0 +1,160 @@
+package rel_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jzelinskie/gochugaru/rel"
+)
+
+type encoder interface {
+	Append(r *rel.Relationship) error
+	Close() error
+}
+
+type decoder interface {
+	Next() (rel.Relationship, error)
+}
+
+func decodeAll(t *testing.T, dec decoder) []rel.Relationship {
+	t.Helper()
+
+	var decoded []rel.Relationship
+	for {
+		r, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			return decoded
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, r)
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	rels := []rel.Relationship{
+		rel.MustFromTriple("document:example", "viewer", "user:jzelinskie"),
+		rel.MustFromTriple("document:example", "viewer", "group:eng#member"),
+		rel.MustFromTriple("document:example", "viewer", "user:*"),
+		rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithCaveat("is_weekday", nil),
+		rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithCaveat("is_weekday", map[string]any{
+			"day":   "monday, \"probably\"",
+			"hours": []any{int64(9), int64(17)},
+			"id":    int64(9007199254740993),
+		}),
+		rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithExpiration(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+
+	cases := []struct {
+		name string
+		enc  func(io.Writer) encoder
+		dec  func(io.Reader) decoder
+	}{
+		{"jsonl", func(w io.Writer) encoder { return rel.NewJSONLEncoder(w) }, func(r io.Reader) decoder { return rel.NewJSONLDecoder(r) }},
+		{"csv", func(w io.Writer) encoder { return rel.NewCSVEncoder(w) }, func(r io.Reader) decoder { return rel.NewCSVDecoder(r) }},
+		{"yaml", func(w io.Writer) encoder { return rel.NewYAMLEncoder(w) }, func(r io.Reader) decoder { return rel.NewYAMLDecoder(r) }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, rs := range [][]rel.Relationship{rels, nil} {
+				var buf bytes.Buffer
+				enc := c.enc(&buf)
+				for _, r := range rs {
+					if err := enc.Append(&r); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := enc.Close(); err != nil {
+					t.Fatal(err)
+				}
+
+				if decoded := decodeAll(t, c.dec(&buf)); !reflect.DeepEqual(decoded, rs) {
+					t.Fatalf("unexpected relationships:\n%v\nexpected:\n%v", decoded, rs)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodingExpirationUTC(t *testing.T) {
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := rel.MustFromTriple("document:example", "viewer", "user:jzelinskie")
+	utc := r.WithExpiration(expiration)
+	local := r.WithExpiration(expiration.In(time.FixedZone("EST", -5*60*60)))
+
+	encoders := map[string]func(io.Writer) encoder{
+		"jsonl": func(w io.Writer) encoder { return rel.NewJSONLEncoder(w) },
+		"csv":   func(w io.Writer) encoder { return rel.NewCSVEncoder(w) },
+		"yaml":  func(w io.Writer) encoder { return rel.NewYAMLEncoder(w) },
+	}
+	for name, newEncoder := range encoders {
+		t.Run(name, func(t *testing.T) {
+			var encoded [2]bytes.Buffer
+			for i, r := range []rel.Relationship{utc, local} {
+				enc := newEncoder(&encoded[i])
+				if err := enc.Append(&r); err != nil {
+					t.Fatal(err)
+				} else if err := enc.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if encoded[0].String() != encoded[1].String() {
+				t.Fatalf("expected expirations to be encoded in UTC:\n%s\n%s", &encoded[0], &encoded[1])
+			}
+		})
+	}
+}
+
+func TestYAMLDecoderValidationFile(t *testing.T) {
+	const file = `schema: |-
+  definition user {}
+
+  definition document {
+    relation viewer: user with is_weekday
+  }
+relationships: |-
+  // the author
+  document:example#viewer@user:jzelinskie
+
+  document:example#viewer@user:someone[is_weekday:{"day":"monday"}]
+assertions:
+  assertTrue:
+    - document:example#viewer@user:jzelinskie
+`
+
+	expected := []rel.Relationship{
+		rel.MustFromTriple("document:example", "viewer", "user:jzelinskie"),
+		rel.MustFromTriple("document:example", "viewer", "user:someone").WithCaveat("is_weekday", map[string]any{"day": "monday"}),
+	}
+	if decoded := decodeAll(t, rel.NewYAMLDecoder(strings.NewReader(file))); !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("unexpected relationships:\n%v\nexpected:\n%v", decoded, expected)
+	}
+}
+
+func TestDecodersRejectInvalidRelationships(t *testing.T) {
+	cases := []struct {
+		name        string
+		dec         decoder
+		expectedErr error
+	}{
+		{"jsonl missing subject", rel.NewJSONLDecoder(strings.NewReader(`{"resource_type":"document","resource_id":"example","resource_relation":"viewer"}`)), rel.ErrInvalidSubject},
+		{"csv missing relation", rel.NewCSVDecoder(strings.NewReader("resource_type,resource_id,resource_relation,subject_type,subject_id\ndocument,example,,user,jzelinskie\n")), rel.ErrInvalidRelation},
+		{"csv invalid context", rel.NewCSVDecoder(strings.NewReader("resource_type,resource_id,resource_relation,subject_type,subject_id,caveat_name,caveat_context\ndocument,example,viewer,user,jzelinskie,is_weekday,{\n")), rel.ErrInvalidCaveat},
+		{"yaml unterminated caveat", rel.NewYAMLDecoder(strings.NewReader("relationships: document:example#viewer@user:jzelinskie[is_weekday\n")), rel.ErrInvalidCaveat},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := c.dec.Next(); !errors.Is(err, c.expectedErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package rel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// These patterns match the names and IDs accepted by SpiceDB.
+var (
+	typeRegex     = regexp.MustCompile(`^([a-z][a-z0-9_]{1,61}[a-z0-9]/)*[a-z][a-z0-9_]{1,62}[a-z0-9]$`)
+	idRegex       = regexp.MustCompile(`^[a-zA-Z0-9/_|\-=+]+$`)
+	relationRegex = regexp.MustCompile(`^[a-z][a-z0-9_]{1,62}[a-z0-9]$`)
+)
+
+const (
+	// ellipsisRelation is the relation that can be explicitly provided for
+	// subjects without a relation.
+	ellipsisRelation = "..."
+
+	wildcardID    = "*"
+	expirationKey = "expiration"
+	maxIDLength   = 1024
+)
+
+func validID(id string) bool { return len(id) <= maxIDLength && idRegex.MatchString(id) }
+
+// String returns the relationship in the syntax used by SpiceDB tooling:
+//
+//	document:example#viewer@user:jzelinskie[is_weekday:{"day":"monday"}][expiration:2030-01-01T00:00:00Z]
+//
+// Caveat contexts are encoded as JSON with sorted keys, and an empty but
+// non-nil context is encoded as `{}`. A context that cannot be encoded as JSON
+// is formatted with the fmt package and cannot be parsed.
+func (r Relationship) String() string {
+	s, _ := r.format()
+	return s
+}
+
+// format returns the relationship as formatted by String along with an error
+// if the relationship cannot be parsed because its caveat context cannot be
+// encoded as JSON.
+func (r Relationship) format() (string, error) {
+	var b strings.Builder
+	b.WriteString(r.ResourceType + ":" + r.ResourceID + "#" + r.ResourceRelation)
+	b.WriteString("@" + r.SubjectType + ":" + r.SubjectID)
+	if r.SubjectRelation != "" {
+		b.WriteString("#" + r.SubjectRelation)
+	}
+
+	var err error
+	if r.HasCaveat() {
+		b.WriteString("[" + r.CaveatName)
+		switch {
+		case r.CaveatContext == nil:
+		case len(r.CaveatContext) == 0:
+			b.WriteString(":{}")
+		default:
+			var context []byte
+			if context, err = marshalContext(r.CaveatContext); err != nil {
+				context = []byte(fmt.Sprint(r.CaveatContext))
+			}
+			b.WriteString(":" + string(context))
+		}
+		b.WriteString("]")
+	}
+
+	if !r.Expiration.IsZero() {
+		b.WriteString("[" + expirationKey + ":" + r.Expiration.UTC().Format(time.RFC3339Nano) + "]")
+	}
+	return b.String(), err
+}
+
+// MustParse calls Parse and panics if the relationship is invalid.
+func MustParse(s string) Relationship {
+	r, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Parse parses a relationship in the syntax used by SpiceDB tooling and
+// produced by Relationship.String.
+//
+// The subject can be a wildcard (e.g. `user:*`) and can have a relation,
+// where `...` is equivalent to no relation. The subject can be followed by a
+// caveat with an optional JSON context (e.g. `[is_weekday:{"day":"monday"}]`)
+// and then an RFC 3339 expiration (e.g. `[expiration:2030-01-01T00:00:00Z]`).
+//
+// Integers in the context are parsed as int64 (or uint64 if they are too
+// large) rather than float64 so that they keep their precision.
+func Parse(s string) (Relationship, error) {
+	var r Relationship
+
+	resource, subject, found := strings.Cut(s, "@")
+	if !found {
+		return r, fmt.Errorf("%w: missing subject in %q", ErrInvalidSubject, s)
+	}
+
+	resource, r.ResourceRelation, found = strings.Cut(resource, "#")
+	if !found || !relationRegex.MatchString(r.ResourceRelation) {
+		return r, fmt.Errorf("%w: %q", ErrInvalidRelation, r.ResourceRelation)
+	}
+
+	r.ResourceType, r.ResourceID, found = strings.Cut(resource, ":")
+	if !found || !typeRegex.MatchString(r.ResourceType) || !validID(r.ResourceID) {
+		return r, fmt.Errorf("%w: %q", ErrInvalidResource, resource)
+	}
+
+	// IDs cannot contain brackets, so the first one ends the subject.
+	var optional string
+	if i := strings.IndexByte(subject, '['); i >= 0 {
+		subject, optional = subject[:i], subject[i:]
+	}
+
+	object, relation, hasRelation := strings.Cut(subject, "#")
+	r.SubjectType, r.SubjectID, found = strings.Cut(object, ":")
+	switch {
+	case !found || !typeRegex.MatchString(r.SubjectType):
+		return r, fmt.Errorf("%w: %q", ErrInvalidSubject, subject)
+	case r.SubjectID != wildcardID && !validID(r.SubjectID):
+		return r, fmt.Errorf("%w: %q", ErrInvalidSubject, subject)
+	case hasRelation && relation != ellipsisRelation && !relationRegex.MatchString(relation):
+		return r, fmt.Errorf("%w: %q", ErrInvalidSubject, subject)
+	case r.SubjectID == wildcardID && hasRelation && relation != ellipsisRelation:
+		return r, fmt.Errorf("%w: wildcards cannot have a relation: %q", ErrInvalidSubject, subject)
+	}
+	if relation != ellipsisRelation {
+		r.SubjectRelation = relation
+	}
+
+	return r, parseOptional(&r, optional)
+}
+
+// parseOptional parses the bracketed caveat and expiration that can follow
+// the subject of a relationship.
+func parseOptional(r *Relationship, s string) error {
+	if s == "" {
+		return nil
+	}
+
+	name, rest, err := cutBracket(s)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCaveat, err)
+	}
+
+	// A caveat named expiration is distinguished by having a JSON object
+	// context, if any, rather than a timestamp.
+	if timestamp, ok := strings.CutPrefix(name, expirationKey+":"); ok && !strings.HasPrefix(timestamp, "{") {
+		if rest != "" {
+			return fmt.Errorf("%w: unexpected %q after expiration", ErrInvalidExpiration, rest)
+		}
+
+		r.Expiration, err = time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidExpiration, err)
+		}
+		return nil
+	}
+
+	name, context, hasContext := strings.Cut(name, ":")
+	if !typeRegex.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidCaveat, name)
+	}
+	r.CaveatName = name
+
+	if hasContext {
+		if r.CaveatContext, err = unmarshalContext(context); err != nil {
+			return err
+		}
+	}
+
+	if rest == "" {
+		return nil
+	}
+	if err := parseOptional(r, rest); err != nil {
+		return err
+	} else if r.Expiration.IsZero() {
+		return fmt.Errorf("%w: multiple caveats", ErrInvalidCaveat)
+	}
+	return nil
+}
+
+// cutBracket returns the contents of the bracketed section at the start of
+// the string and the remainder of the string.
+//
+// Brackets within JSON strings are ignored.
+func cutBracket(s string) (contents, rest string, err error) {
+	if !strings.HasPrefix(s, "[") {
+		return "", "", fmt.Errorf("expected '[' at %q", s)
+	}
+
+	var inString, escaped bool
+	depth := 0
+	for i, c := range []byte(s) {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				if c != ']' {
+					return "", "", fmt.Errorf("mismatched brackets in %q", s)
+				}
+				return s[1:i], s[i+1:], nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("unterminated %q", s)
+}
+
+// validate returns an error if any of the required fields of the
+// relationship are empty.
+func (r Relationship) validate() error {
+	switch {
+	case r.ResourceType == "" || r.ResourceID == "":
+		return ErrInvalidResource
+	case r.ResourceRelation == "":
+		return ErrInvalidRelation
+	case r.SubjectType == "" || r.SubjectID == "":
+		return ErrInvalidSubject
+	}
+	return nil
+}
+
+// unmarshalContext decodes a caveat context from a JSON object.
+//
+// Integers are decoded as int64 or uint64 rather than float64 so that they
+// keep their precision.
+func unmarshalContext(s string) (map[string]any, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	var context map[string]any
+	if err := dec.Decode(&context); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCaveat, err)
+	} else if dec.More() || context == nil {
+		return nil, fmt.Errorf("%w: context must be a JSON object", ErrInvalidCaveat)
+	}
+	if _, err := convertNumbers(context); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCaveat, err)
+	}
+	return context, nil
+}
+
+// convertNumbers replaces the json.Numbers within a decoded JSON value with
+// the first of int64, uint64, or float64 that can represent them.
+func convertNumbers(v any) (any, error) {
+	var err error
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if v[key], err = convertNumbers(value); err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, value := range v {
+			if v[i], err = convertNumbers(value); err != nil {
+				return nil, err
+			}
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		} else if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return v.Float64()
+	}
+	return v, nil
+}
+
+// marshalContext encodes a caveat context as compact JSON with sorted keys,
+// or returns nil if the context is empty.
+func marshalContext(context map[string]any) ([]byte, error) {
+	if len(context) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(context); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCaveat, err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
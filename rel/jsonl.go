@@ -0,0 +1,131 @@
+package rel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxLineSize is the longest line that can be decoded, which limits the size
+// of caveat contexts.
+const maxLineSize = 1 << 20
+
+// jsonRelationship is the JSON representation of a relationship.
+type jsonRelationship struct {
+	ResourceType     string         `json:"resource_type"`
+	ResourceID       string         `json:"resource_id"`
+	ResourceRelation string         `json:"resource_relation"`
+	SubjectType      string         `json:"subject_type"`
+	SubjectID        string         `json:"subject_id"`
+	SubjectRelation  string         `json:"subject_relation,omitempty"`
+	CaveatName       string         `json:"caveat_name,omitempty"`
+	CaveatContext    map[string]any `json:"caveat_context,omitempty"`
+	Expiration       *time.Time     `json:"expiration,omitempty"`
+}
+
+func (jr jsonRelationship) relationship() Relationship {
+	r := Relationship{
+		ResourceType:     jr.ResourceType,
+		ResourceID:       jr.ResourceID,
+		ResourceRelation: jr.ResourceRelation,
+		SubjectType:      jr.SubjectType,
+		SubjectID:        jr.SubjectID,
+		SubjectRelation:  jr.SubjectRelation,
+		CaveatName:       jr.CaveatName,
+		CaveatContext:    jr.CaveatContext,
+	}
+	if jr.Expiration != nil {
+		r.Expiration = *jr.Expiration
+	}
+	return r
+}
+
+// JSONLEncoder writes relationships as JSON Lines: one JSON object per line.
+type JSONLEncoder struct {
+	enc *json.Encoder
+}
+
+// NewJSONLEncoder creates a JSONLEncoder that writes to the provided writer.
+func NewJSONLEncoder(w io.Writer) *JSONLEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONLEncoder{enc: enc}
+}
+
+// Append writes a relationship.
+//
+// Append can be used directly as a Func (e.g. to export relationships).
+func (e *JSONLEncoder) Append(r *Relationship) error {
+	jr := jsonRelationship{
+		ResourceType:     r.ResourceType,
+		ResourceID:       r.ResourceID,
+		ResourceRelation: r.ResourceRelation,
+		SubjectType:      r.SubjectType,
+		SubjectID:        r.SubjectID,
+		SubjectRelation:  r.SubjectRelation,
+		CaveatName:       r.CaveatName,
+		CaveatContext:    r.CaveatContext,
+	}
+	if !r.Expiration.IsZero() {
+		expiration := r.Expiration.UTC()
+		jr.Expiration = &expiration
+	}
+	return e.enc.Encode(jr)
+}
+
+// Close finishes writing relationships.
+//
+// It does not close the underlying writer.
+func (e *JSONLEncoder) Close() error { return nil }
+
+// JSONLDecoder reads relationships written by a JSONLEncoder.
+//
+// Empty lines are ignored.
+type JSONLDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewJSONLDecoder creates a JSONLDecoder that reads from the provided reader.
+func NewJSONLDecoder(r io.Reader) *JSONLDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	return &JSONLDecoder{scanner: scanner}
+}
+
+// Next returns the next relationship or io.EOF once all relationships have
+// been read.
+func (d *JSONLDecoder) Next() (Relationship, error) {
+	for d.scanner.Scan() {
+		d.line++
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var jr jsonRelationship
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		if err := dec.Decode(&jr); err != nil {
+			return Relationship{}, fmt.Errorf("line %d: %w", d.line, err)
+		} else if dec.More() {
+			return Relationship{}, fmt.Errorf("line %d: unexpected data after relationship", d.line)
+		} else if _, err := convertNumbers(jr.CaveatContext); err != nil {
+			return Relationship{}, fmt.Errorf("line %d: %w: %w", d.line, ErrInvalidCaveat, err)
+		}
+
+		r := jr.relationship()
+		if err := r.validate(); err != nil {
+			return Relationship{}, fmt.Errorf("line %d: %w", d.line, err)
+		}
+		return r, nil
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return Relationship{}, err
+	}
+	return Relationship{}, io.EOF
+}
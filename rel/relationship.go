@@ -3,9 +3,11 @@ package rel
 import (
 	"errors"
 	"strings"
+	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var (
@@ -17,6 +19,12 @@ var (
 
 	// ErrInvalidSubject is a catch-all error when a subject is invalid.
 	ErrInvalidSubject = errors.New("invalid subject")
+
+	// ErrInvalidCaveat is a catch-all error when a caveat is invalid.
+	ErrInvalidCaveat = errors.New("invalid caveat")
+
+	// ErrInvalidExpiration is a catch-all error when an expiration is invalid.
+	ErrInvalidExpiration = errors.New("invalid expiration")
 )
 
 type Interface interface{ Relationship() Relationship }
@@ -30,6 +38,10 @@ type Relationship struct {
 	SubjectRelation  string
 	CaveatName       string
 	CaveatContext    map[string]any
+
+	// Expiration is the time after which the relationship no longer exists.
+	// The zero value means the relationship never expires.
+	Expiration time.Time
 }
 
 func (r Relationship) Relationship() Relationship { return r }
@@ -41,16 +53,15 @@ func (r Relationship) Caveat() (name string, context map[string]any, exists bool
 }
 
 func (r Relationship) WithCaveat(name string, context map[string]any) Relationship {
-	return Relationship{
-		ResourceType:     r.ResourceType,
-		ResourceID:       r.ResourceID,
-		ResourceRelation: r.ResourceRelation,
-		SubjectType:      r.SubjectType,
-		SubjectID:        r.SubjectID,
-		SubjectRelation:  r.SubjectRelation,
-		CaveatName:       name,
-		CaveatContext:    context,
-	}
+	r.CaveatName, r.CaveatContext = name, context
+	return r
+}
+
+// WithExpiration returns a copy of the relationship that expires at the
+// provided time.
+func (r Relationship) WithExpiration(t time.Time) Relationship {
+	r.Expiration = t
+	return r
 }
 
 func (r Relationship) Filter() *Filter {
@@ -59,8 +70,9 @@ func (r Relationship) Filter() *Filter {
 	return f
 }
 
-func (r Relationship) v1() *v1.Relationship {
-	return &v1.Relationship{
+// V1Proto converts the relationship into its protobuf representation.
+func (r Relationship) V1Proto() *v1.Relationship {
+	v1r := &v1.Relationship{
 		Resource: &v1.ObjectReference{
 			ObjectType: r.ResourceType,
 			ObjectId:   r.ResourceID,
@@ -75,6 +87,10 @@ func (r Relationship) v1() *v1.Relationship {
 		},
 		OptionalCaveat: r.MustV1ProtoCaveat(),
 	}
+	if !r.Expiration.IsZero() {
+		v1r.OptionalExpiresAt = timestamppb.New(r.Expiration)
+	}
+	return v1r
 }
 
 func FromV1Proto(r *v1.Relationship) *Relationship {
@@ -82,7 +98,14 @@ func FromV1Proto(r *v1.Relationship) *Relationship {
 	var caveatContext map[string]any
 	if r.OptionalCaveat != nil {
 		caveatName = r.OptionalCaveat.CaveatName
-		caveatContext = r.OptionalCaveat.Context.AsMap()
+		if r.OptionalCaveat.Context != nil {
+			caveatContext = r.OptionalCaveat.Context.AsMap()
+		}
+	}
+
+	var expiration time.Time
+	if r.OptionalExpiresAt != nil {
+		expiration = r.OptionalExpiresAt.AsTime()
 	}
 
 	return &Relationship{
@@ -94,6 +117,7 @@ func FromV1Proto(r *v1.Relationship) *Relationship {
 		SubjectRelation:  r.Subject.OptionalRelation,
 		CaveatName:       caveatName,
 		CaveatContext:    caveatContext,
+		Expiration:       expiration,
 	}
 }
 
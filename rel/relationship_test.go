@@ -1,8 +1,11 @@
 package rel_test
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/jzelinskie/gochugaru/rel"
 )
@@ -27,11 +30,11 @@ func TestRelationshipParsingTriples(t *testing.T) {
 	}
 }
 
-func ExampleMustRelationshipFromTriple() {
+func ExampleMustFromTriple() {
 	r := rel.MustFromTriple("document:example", "viewer", "user:jzelinskie")
 	fmt.Println(r)
 	// Output:
-	// {document example viewer user jzelinskie   map[]}
+	// document:example#viewer@user:jzelinskie
 }
 
 func ExampleRelationship_WithCaveat() {
@@ -40,5 +43,149 @@ func ExampleRelationship_WithCaveat() {
 		WithCaveat("only_on_tuesday", map[string]any{"day_of_the_week": "wednesday"}),
 	)
 	// Output:
-	// {document example viewer user jzelinskie  only_on_tuesday map[day_of_the_week:wednesday]}
+	// document:example#viewer@user:jzelinskie[only_on_tuesday:{"day_of_the_week":"wednesday"}]
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		s        string
+		expected rel.Relationship
+	}{
+		{
+			"subject",
+			"document:example#viewer@user:jzelinskie",
+			rel.MustFromTriple("document:example", "viewer", "user:jzelinskie"),
+		},
+		{
+			"subject relation",
+			"document:example#viewer@group:eng#member",
+			rel.MustFromTriple("document:example", "viewer", "group:eng#member"),
+		},
+		{
+			"wildcard",
+			"document:example#viewer@user:*",
+			rel.MustFromTriple("document:example", "viewer", "user:*"),
+		},
+		{
+			"prefixed types",
+			"tenant/document:a/b|c-d=e+f#viewer@tenant/user:jzelinskie",
+			rel.MustFromTriple("tenant/document:a/b|c-d=e+f", "viewer", "tenant/user:jzelinskie"),
+		},
+		{
+			"caveat",
+			"document:example#viewer@user:jzelinskie[is_weekday]",
+			rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithCaveat("is_weekday", nil),
+		},
+		{
+			"caveat context",
+			`document:example#viewer@user:jzelinskie[is_weekday:{"days":["mon","tue]"],"note":"<a&b>","offset":1.5}]`,
+			rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithCaveat("is_weekday", map[string]any{
+				"days":   []any{"mon", "tue]"},
+				"note":   "<a&b>",
+				"offset": 1.5,
+			}),
+		},
+		{
+			"empty caveat context",
+			"document:example#viewer@user:jzelinskie[is_weekday:{}]",
+			rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithCaveat("is_weekday", map[string]any{}),
+		},
+		{
+			"large integers in caveat context",
+			`document:example#viewer@user:jzelinskie[is_weekday:{"ids":[9007199254740993],"max":18446744073709551615,"min":-9007199254740993}]`,
+			rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithCaveat("is_weekday", map[string]any{
+				"max": uint64(18446744073709551615),
+				"min": int64(-9007199254740993),
+				"ids": []any{int64(9007199254740993)},
+			}),
+		},
+		{
+			"caveat named expiration",
+			`document:example#viewer@user:jzelinskie[expiration:{"at":"now"}]`,
+			rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithCaveat("expiration", map[string]any{"at": "now"}),
+		},
+		{
+			"expiration",
+			"document:example#viewer@user:jzelinskie[expiration:2030-01-02T03:04:05Z]",
+			rel.MustFromTriple("document:example", "viewer", "user:jzelinskie").WithExpiration(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)),
+		},
+		{
+			"caveat and expiration",
+			`document:example#viewer@group:eng#member[is_weekday:{"day":"monday"}][expiration:2030-01-02T03:04:05.5Z]`,
+			rel.MustFromTriple("document:example", "viewer", "group:eng#member").
+				WithCaveat("is_weekday", map[string]any{"day": "monday"}).
+				WithExpiration(time.Date(2030, 1, 2, 3, 4, 5, 500_000_000, time.UTC)),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := rel.Parse(c.s)
+			if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(r, c.expected) {
+				t.Fatalf("unexpected relationship: %#v", r)
+			} else if r.String() != c.s {
+				t.Fatalf("unexpected string: %s", r.String())
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name        string
+		s           string
+		expectedErr error
+	}{
+		{"missing subject", "document:example#viewer", rel.ErrInvalidSubject},
+		{"missing relation", "document:example@user:jzelinskie", rel.ErrInvalidRelation},
+		{"missing resource id", "document#viewer@user:jzelinskie", rel.ErrInvalidResource},
+		{"invalid resource id", "document:ex ample#viewer@user:jzelinskie", rel.ErrInvalidResource},
+		{"invalid subject type", "document:example#viewer@User:jzelinskie", rel.ErrInvalidSubject},
+		{"wildcard relation", "document:example#viewer@user:*#member", rel.ErrInvalidSubject},
+		{"unterminated caveat", "document:example#viewer@user:jzelinskie[is_weekday", rel.ErrInvalidCaveat},
+		{"invalid context", `document:example#viewer@user:jzelinskie[is_weekday:{"day"}]`, rel.ErrInvalidCaveat},
+		{"out of range number", `document:example#viewer@user:jzelinskie[is_weekday:{"n":1e400}]`, rel.ErrInvalidCaveat},
+		{"multiple caveats", "document:example#viewer@user:jzelinskie[is_weekday][is_tuesday]", rel.ErrInvalidCaveat},
+		{"invalid expiration", "document:example#viewer@user:jzelinskie[expiration:tomorrow]", rel.ErrInvalidExpiration},
+		{"caveat after expiration", "document:example#viewer@user:jzelinskie[expiration:2030-01-02T03:04:05Z][is_weekday]", rel.ErrInvalidExpiration},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := rel.Parse(c.s); !errors.Is(err, c.expectedErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestStringExpirationUTC(t *testing.T) {
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := rel.MustFromTriple("document:example", "viewer", "user:jzelinskie")
+
+	utc := r.WithExpiration(expiration).String()
+	local := r.WithExpiration(expiration.In(time.FixedZone("EST", -5*60*60))).String()
+	if expected := "document:example#viewer@user:jzelinskie[expiration:2030-01-02T03:04:05Z]"; utc != expected || local != expected {
+		t.Fatalf("expected %s, got %s and %s", expected, utc, local)
+	}
+}
+
+func TestParseEllipsis(t *testing.T) {
+	r := rel.MustParse("document:example#viewer@user:jzelinskie#...")
+	if expected := "document:example#viewer@user:jzelinskie"; r.String() != expected {
+		t.Fatalf("unexpected string: %s", r)
+	}
+}
+
+func ExampleParse() {
+	r, err := rel.Parse(`document:example#viewer@user:jzelinskie[only_on_tuesday:{"day_of_the_week":"wednesday"}]`)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(r.SubjectID, r.CaveatName, r.CaveatContext)
+	// Output:
+	// jzelinskie only_on_tuesday map[day_of_the_week:wednesday]
 }
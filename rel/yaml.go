@@ -0,0 +1,117 @@
+package rel
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLEncoder writes relationships as the `relationships` block of a zed
+// validation file.
+//
+// Because validation files are YAML maps, the output can be concatenated
+// with the other blocks of a validation file (e.g. `schema`).
+type YAMLEncoder struct {
+	w     *bufio.Writer
+	wrote bool
+}
+
+// NewYAMLEncoder creates a YAMLEncoder that writes to the provided writer.
+func NewYAMLEncoder(w io.Writer) *YAMLEncoder {
+	return &YAMLEncoder{w: bufio.NewWriter(w)}
+}
+
+// Append writes a relationship.
+//
+// Append can be used directly as a Func (e.g. to export relationships).
+func (e *YAMLEncoder) Append(r *Relationship) error {
+	line, err := r.format()
+	if err != nil {
+		return err
+	}
+
+	if !e.wrote {
+		e.wrote = true
+		if _, err := e.w.WriteString("relationships: |-\n"); err != nil {
+			return err
+		}
+	}
+	_, err = e.w.WriteString("  " + line + "\n")
+	return err
+}
+
+// Close flushes any buffered relationships.
+//
+// It does not close the underlying writer.
+func (e *YAMLEncoder) Close() error {
+	if !e.wrote {
+		e.wrote = true
+		if _, err := e.w.WriteString("relationships: \"\"\n"); err != nil {
+			return err
+		}
+	}
+	return e.w.Flush()
+}
+
+// YAMLDecoder reads relationships from the `relationships` block of a zed
+// validation file.
+//
+// The other blocks of the validation file are ignored, as are empty lines
+// and lines beginning with `//` in the relationships block.
+type YAMLDecoder struct {
+	r     io.Reader
+	lines []string
+	line  int
+}
+
+// NewYAMLDecoder creates a YAMLDecoder that reads from the provided reader.
+//
+// The validation file is read in its entirety on the first call to Next.
+func NewYAMLDecoder(r io.Reader) *YAMLDecoder {
+	return &YAMLDecoder{r: r}
+}
+
+func (d *YAMLDecoder) read() error {
+	if d.r == nil {
+		return nil
+	}
+
+	var file struct {
+		Relationships string `yaml:"relationships"`
+	}
+	err := yaml.NewDecoder(d.r).Decode(&file)
+	d.r = nil
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid validation file: %w", err)
+	}
+
+	d.lines = strings.Split(file.Relationships, "\n")
+	return nil
+}
+
+// Next returns the next relationship or io.EOF once all relationships have
+// been read.
+func (d *YAMLDecoder) Next() (Relationship, error) {
+	if err := d.read(); err != nil {
+		return Relationship{}, err
+	}
+
+	for d.line < len(d.lines) {
+		line := strings.TrimSpace(d.lines[d.line])
+		d.line++
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		r, err := Parse(line)
+		if err != nil {
+			return Relationship{}, fmt.Errorf("relationships line %d: %w", d.line, err)
+		}
+		return r, nil
+	}
+	return Relationship{}, io.EOF
+}
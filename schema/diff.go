@@ -0,0 +1,177 @@
+package schema
+
+import (
+	"fmt"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+// DiffKind is the kind of change described by a Diff.
+type DiffKind int
+
+// The kinds of changes that can be found between two schemas.
+const (
+	DiffUnknown DiffKind = iota
+	DefinitionAdded
+	DefinitionRemoved
+	DefinitionCommentChanged
+	RelationAdded
+	RelationRemoved
+	RelationCommentChanged
+	RelationSubjectTypeAdded
+	RelationSubjectTypeRemoved
+	PermissionAdded
+	PermissionRemoved
+	PermissionCommentChanged
+	PermissionExprChanged
+	CaveatAdded
+	CaveatRemoved
+	CaveatCommentChanged
+	CaveatExprChanged
+	CaveatParameterAdded
+	CaveatParameterRemoved
+	CaveatParameterTypeChanged
+)
+
+var diffKindNames = [...]string{
+	DiffUnknown:                "unknown",
+	DefinitionAdded:            "definition_added",
+	DefinitionRemoved:          "definition_removed",
+	DefinitionCommentChanged:   "definition_comment_changed",
+	RelationAdded:              "relation_added",
+	RelationRemoved:            "relation_removed",
+	RelationCommentChanged:     "relation_comment_changed",
+	RelationSubjectTypeAdded:   "relation_subject_type_added",
+	RelationSubjectTypeRemoved: "relation_subject_type_removed",
+	PermissionAdded:            "permission_added",
+	PermissionRemoved:          "permission_removed",
+	PermissionCommentChanged:   "permission_comment_changed",
+	PermissionExprChanged:      "permission_expr_changed",
+	CaveatAdded:                "caveat_added",
+	CaveatRemoved:              "caveat_removed",
+	CaveatCommentChanged:       "caveat_comment_changed",
+	CaveatExprChanged:          "caveat_expr_changed",
+	CaveatParameterAdded:       "caveat_parameter_added",
+	CaveatParameterRemoved:     "caveat_parameter_removed",
+	CaveatParameterTypeChanged: "caveat_parameter_type_changed",
+}
+
+func (k DiffKind) String() string {
+	if k < 0 || int(k) >= len(diffKindNames) {
+		return diffKindNames[DiffUnknown]
+	}
+	return diffKindNames[k]
+}
+
+// Diff is a single difference between two schemas.
+//
+// Only the fields relevant to the Kind are set: for example, a
+// RelationSubjectTypeAdded diff sets Relation and SubjectType.
+type Diff struct {
+	Kind DiffKind
+
+	Definition  *Definition
+	Relation    *Relation
+	SubjectType *SubjectType
+	Permission  *Permission
+	Caveat      *Caveat
+	Parameter   *CaveatParameter
+
+	// PreviousType is the type of the parameter before a
+	// CaveatParameterTypeChanged diff.
+	PreviousType string
+}
+
+func (d Diff) String() string {
+	switch {
+	case d.Definition != nil:
+		return fmt.Sprintf("%s %s", d.Kind, d.Definition.Name)
+	case d.SubjectType != nil && d.Relation != nil:
+		return fmt.Sprintf("%s %s#%s %s", d.Kind, d.Relation.Definition, d.Relation.Name, d.SubjectType)
+	case d.Relation != nil:
+		return fmt.Sprintf("%s %s#%s", d.Kind, d.Relation.Definition, d.Relation.Name)
+	case d.Permission != nil:
+		return fmt.Sprintf("%s %s#%s", d.Kind, d.Permission.Definition, d.Permission.Name)
+	case d.Caveat != nil:
+		return fmt.Sprintf("%s %s", d.Kind, d.Caveat.Name)
+	case d.Parameter != nil && d.PreviousType != "":
+		return fmt.Sprintf("%s %s.%s %s -> %s", d.Kind, d.Parameter.Caveat, d.Parameter.Name, d.PreviousType, d.Parameter.Type)
+	case d.Parameter != nil:
+		return fmt.Sprintf("%s %s.%s", d.Kind, d.Parameter.Caveat, d.Parameter.Name)
+	}
+	return d.Kind.String()
+}
+
+// DiffFromV1Proto creates a Diff from its protobuf representation.
+func DiffFromV1Proto(d *v1.ExpSchemaDiff) Diff {
+	definition := func(kind DiffKind, def *v1.ExpDefinition) Diff {
+		converted := definitionFromV1(def)
+		return Diff{Kind: kind, Definition: &converted}
+	}
+	relation := func(kind DiffKind, r *v1.ExpRelation) Diff {
+		converted := relationFromV1(r)
+		return Diff{Kind: kind, Relation: &converted}
+	}
+	subjectType := func(kind DiffKind, c *v1.ExpRelationSubjectTypeChange) Diff {
+		diff := relation(kind, c.GetRelation())
+		st := subjectTypeFromV1(c.GetChangedSubjectType())
+		diff.SubjectType = &st
+		return diff
+	}
+	permission := func(kind DiffKind, p *v1.ExpPermission) Diff {
+		converted := permissionFromV1(p)
+		return Diff{Kind: kind, Permission: &converted}
+	}
+	caveat := func(kind DiffKind, c *v1.ExpCaveat) Diff {
+		converted := caveatFromV1(c)
+		return Diff{Kind: kind, Caveat: &converted}
+	}
+	parameter := func(kind DiffKind, p *v1.ExpCaveatParameter) Diff {
+		converted := caveatParameterFromV1(p)
+		return Diff{Kind: kind, Parameter: &converted}
+	}
+
+	switch diff := d.GetDiff().(type) {
+	case *v1.ExpSchemaDiff_DefinitionAdded:
+		return definition(DefinitionAdded, diff.DefinitionAdded)
+	case *v1.ExpSchemaDiff_DefinitionRemoved:
+		return definition(DefinitionRemoved, diff.DefinitionRemoved)
+	case *v1.ExpSchemaDiff_DefinitionDocCommentChanged:
+		return definition(DefinitionCommentChanged, diff.DefinitionDocCommentChanged)
+	case *v1.ExpSchemaDiff_RelationAdded:
+		return relation(RelationAdded, diff.RelationAdded)
+	case *v1.ExpSchemaDiff_RelationRemoved:
+		return relation(RelationRemoved, diff.RelationRemoved)
+	case *v1.ExpSchemaDiff_RelationDocCommentChanged:
+		return relation(RelationCommentChanged, diff.RelationDocCommentChanged)
+	case *v1.ExpSchemaDiff_RelationSubjectTypeAdded:
+		return subjectType(RelationSubjectTypeAdded, diff.RelationSubjectTypeAdded)
+	case *v1.ExpSchemaDiff_RelationSubjectTypeRemoved:
+		return subjectType(RelationSubjectTypeRemoved, diff.RelationSubjectTypeRemoved)
+	case *v1.ExpSchemaDiff_PermissionAdded:
+		return permission(PermissionAdded, diff.PermissionAdded)
+	case *v1.ExpSchemaDiff_PermissionRemoved:
+		return permission(PermissionRemoved, diff.PermissionRemoved)
+	case *v1.ExpSchemaDiff_PermissionDocCommentChanged:
+		return permission(PermissionCommentChanged, diff.PermissionDocCommentChanged)
+	case *v1.ExpSchemaDiff_PermissionExprChanged:
+		return permission(PermissionExprChanged, diff.PermissionExprChanged)
+	case *v1.ExpSchemaDiff_CaveatAdded:
+		return caveat(CaveatAdded, diff.CaveatAdded)
+	case *v1.ExpSchemaDiff_CaveatRemoved:
+		return caveat(CaveatRemoved, diff.CaveatRemoved)
+	case *v1.ExpSchemaDiff_CaveatDocCommentChanged:
+		return caveat(CaveatCommentChanged, diff.CaveatDocCommentChanged)
+	case *v1.ExpSchemaDiff_CaveatExprChanged:
+		return caveat(CaveatExprChanged, diff.CaveatExprChanged)
+	case *v1.ExpSchemaDiff_CaveatParameterAdded:
+		return parameter(CaveatParameterAdded, diff.CaveatParameterAdded)
+	case *v1.ExpSchemaDiff_CaveatParameterRemoved:
+		return parameter(CaveatParameterRemoved, diff.CaveatParameterRemoved)
+	case *v1.ExpSchemaDiff_CaveatParameterTypeChanged:
+		converted := parameter(CaveatParameterTypeChanged, diff.CaveatParameterTypeChanged.GetParameter())
+		converted.PreviousType = diff.CaveatParameterTypeChanged.GetPreviousType()
+		return converted
+	}
+	return Diff{Kind: DiffUnknown}
+}
@@ -0,0 +1,64 @@
+package schema_test
+
+import (
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/jzelinskie/gochugaru/schema"
+)
+
+func TestDiffFromV1Proto(t *testing.T) {
+	for _, tt := range []struct {
+		diff     *v1.ExpSchemaDiff
+		kind     schema.DiffKind
+		expected string
+	}{
+		{
+			diff: &v1.ExpSchemaDiff{Diff: &v1.ExpSchemaDiff_DefinitionAdded{
+				DefinitionAdded: &v1.ExpDefinition{Name: "folder"},
+			}},
+			kind:     schema.DefinitionAdded,
+			expected: "definition_added folder",
+		},
+		{
+			diff: &v1.ExpSchemaDiff{Diff: &v1.ExpSchemaDiff_RelationSubjectTypeAdded{
+				RelationSubjectTypeAdded: &v1.ExpRelationSubjectTypeChange{
+					Relation: &v1.ExpRelation{Name: "viewer", ParentDefinitionName: "document"},
+					ChangedSubjectType: &v1.ExpTypeReference{
+						SubjectDefinitionName: "user",
+						Typeref:               &v1.ExpTypeReference_IsPublicWildcard{IsPublicWildcard: true},
+					},
+				},
+			}},
+			kind:     schema.RelationSubjectTypeAdded,
+			expected: "relation_subject_type_added document#viewer user:*",
+		},
+		{
+			diff: &v1.ExpSchemaDiff{Diff: &v1.ExpSchemaDiff_PermissionExprChanged{
+				PermissionExprChanged: &v1.ExpPermission{Name: "view", ParentDefinitionName: "document"},
+			}},
+			kind:     schema.PermissionExprChanged,
+			expected: "permission_expr_changed document#view",
+		},
+		{
+			diff: &v1.ExpSchemaDiff{Diff: &v1.ExpSchemaDiff_CaveatParameterTypeChanged{
+				CaveatParameterTypeChanged: &v1.ExpCaveatParameterTypeChange{
+					Parameter:    &v1.ExpCaveatParameter{Name: "day", Type: "string", ParentCaveatName: "is_weekday"},
+					PreviousType: "int",
+				},
+			}},
+			kind:     schema.CaveatParameterTypeChanged,
+			expected: "caveat_parameter_type_changed is_weekday.day int -> string",
+		},
+	} {
+		t.Run(tt.expected, func(t *testing.T) {
+			diff := schema.DiffFromV1Proto(tt.diff)
+			if diff.Kind != tt.kind {
+				t.Fatalf("unexpected kind: %s", diff.Kind)
+			} else if diff.String() != tt.expected {
+				t.Fatalf("unexpected string: %q", diff.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package schema implements types that describe a SpiceDB schema as reported
+// by SpiceDB's reflection APIs.
+package schema
+
+import (
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+// Schema is the structure of a SpiceDB schema.
+type Schema struct {
+	Definitions []Definition
+	Caveats     []Caveat
+}
+
+// Definition returns the definition with the provided name.
+func (s *Schema) Definition(name string) (Definition, bool) {
+	for _, d := range s.Definitions {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Caveat returns the caveat with the provided name.
+func (s *Schema) Caveat(name string) (Caveat, bool) {
+	for _, c := range s.Caveats {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Caveat{}, false
+}
+
+// Definition is an object type (e.g. `definition document {}`).
+type Definition struct {
+	Name        string
+	Comment     string
+	Relations   []Relation
+	Permissions []Permission
+}
+
+// Relation returns the relation with the provided name.
+func (d Definition) Relation(name string) (Relation, bool) {
+	for _, r := range d.Relations {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Relation{}, false
+}
+
+// Permission returns the permission with the provided name.
+func (d Definition) Permission(name string) (Permission, bool) {
+	for _, p := range d.Permissions {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Permission{}, false
+}
+
+// Relation is a relation of a definition.
+type Relation struct {
+	Name    string
+	Comment string
+
+	// Definition is the name of the definition containing the relation.
+	Definition string
+
+	// SubjectTypes are the types of subjects allowed for the relation.
+	SubjectTypes []SubjectType
+}
+
+// SubjectType is a type of subject allowed for a relation (e.g. `user`,
+// `group#member`, or `user:*`).
+type SubjectType struct {
+	// Definition is the name of the subject's definition.
+	Definition string
+
+	// Relation is the relation of the subject, if it isn't a terminal
+	// subject.
+	Relation string
+
+	// Wildcard is true if the subject type allows all subjects of the
+	// definition.
+	Wildcard bool
+
+	// Caveat is the name of the caveat required with the subject, if any.
+	Caveat string
+}
+
+func (st SubjectType) String() string {
+	var b strings.Builder
+	b.WriteString(st.Definition)
+	switch {
+	case st.Wildcard:
+		b.WriteString(":*")
+	case st.Relation != "":
+		b.WriteString("#" + st.Relation)
+	}
+	if st.Caveat != "" {
+		b.WriteString(" with " + st.Caveat)
+	}
+	return b.String()
+}
+
+// Permission is a permission of a definition.
+type Permission struct {
+	Name    string
+	Comment string
+
+	// Definition is the name of the definition containing the permission.
+	Definition string
+}
+
+// Caveat is a named expression that conditionally grants access.
+type Caveat struct {
+	Name       string
+	Comment    string
+	Parameters []CaveatParameter
+	Expression string
+}
+
+// CaveatParameter is a typed parameter of a caveat's expression.
+type CaveatParameter struct {
+	Name string
+	Type string
+
+	// Caveat is the name of the caveat containing the parameter.
+	Caveat string
+}
+
+// RelationReference refers to a relation or permission of a definition.
+type RelationReference struct {
+	Definition   string
+	Relation     string
+	IsPermission bool
+}
+
+func (r RelationReference) String() string { return r.Definition + "#" + r.Relation }
+
+// Filter limits the results of reflecting a schema.
+//
+// Each field is a prefix matched against the name of the respective item;
+// empty fields match everything.
+type Filter struct {
+	Definition string
+	Caveat     string
+	Relation   string
+	Permission string
+}
+
+// V1Filter converts the filter to its protobuf representation.
+func (f Filter) V1Filter() *v1.ExpSchemaFilter {
+	return &v1.ExpSchemaFilter{
+		OptionalDefinitionNameFilter: f.Definition,
+		OptionalCaveatNameFilter:     f.Caveat,
+		OptionalRelationNameFilter:   f.Relation,
+		OptionalPermissionNameFilter: f.Permission,
+	}
+}
+
+// FromV1Proto creates a Schema from the definitions and caveats returned by
+// ExperimentalReflectSchema.
+func FromV1Proto(defs []*v1.ExpDefinition, caveats []*v1.ExpCaveat) *Schema {
+	s := &Schema{}
+	for _, d := range defs {
+		s.Definitions = append(s.Definitions, definitionFromV1(d))
+	}
+	for _, c := range caveats {
+		s.Caveats = append(s.Caveats, caveatFromV1(c))
+	}
+	return s
+}
+
+// RelationReferenceFromV1Proto creates a RelationReference from its protobuf
+// representation.
+func RelationReferenceFromV1Proto(r *v1.ExpRelationReference) RelationReference {
+	return RelationReference{
+		Definition:   r.GetDefinitionName(),
+		Relation:     r.GetRelationName(),
+		IsPermission: r.GetIsPermission(),
+	}
+}
+
+func definitionFromV1(d *v1.ExpDefinition) Definition {
+	def := Definition{Name: d.GetName(), Comment: d.GetComment()}
+	for _, r := range d.GetRelations() {
+		def.Relations = append(def.Relations, relationFromV1(r))
+	}
+	for _, p := range d.GetPermissions() {
+		def.Permissions = append(def.Permissions, permissionFromV1(p))
+	}
+	return def
+}
+
+func relationFromV1(r *v1.ExpRelation) Relation {
+	rel := Relation{
+		Name:       r.GetName(),
+		Comment:    r.GetComment(),
+		Definition: r.GetParentDefinitionName(),
+	}
+	for _, st := range r.GetSubjectTypes() {
+		rel.SubjectTypes = append(rel.SubjectTypes, subjectTypeFromV1(st))
+	}
+	return rel
+}
+
+func subjectTypeFromV1(t *v1.ExpTypeReference) SubjectType {
+	return SubjectType{
+		Definition: t.GetSubjectDefinitionName(),
+		Relation:   t.GetOptionalRelationName(),
+		Wildcard:   t.GetIsPublicWildcard(),
+		Caveat:     t.GetOptionalCaveatName(),
+	}
+}
+
+func permissionFromV1(p *v1.ExpPermission) Permission {
+	return Permission{
+		Name:       p.GetName(),
+		Comment:    p.GetComment(),
+		Definition: p.GetParentDefinitionName(),
+	}
+}
+
+func caveatFromV1(c *v1.ExpCaveat) Caveat {
+	caveat := Caveat{
+		Name:       c.GetName(),
+		Comment:    c.GetComment(),
+		Expression: c.GetExpression(),
+	}
+	for _, p := range c.GetParameters() {
+		caveat.Parameters = append(caveat.Parameters, caveatParameterFromV1(p))
+	}
+	return caveat
+}
+
+func caveatParameterFromV1(p *v1.ExpCaveatParameter) CaveatParameter {
+	return CaveatParameter{
+		Name:   p.GetName(),
+		Type:   p.GetType(),
+		Caveat: p.GetParentCaveatName(),
+	}
+}